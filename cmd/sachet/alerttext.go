@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// AlertTextConf is the layout of the text of receivers without a text
+// template. Each alert is written on a line with the values of Labels,
+// followed by the first of Annotations it has.
+type AlertTextConf struct {
+	// Labels are the labels shown for each alert, in order. Defaults to
+	// alertname, instance and exported_instance.
+	Labels []string `yaml:",omitempty"`
+	// Annotations are the annotations shown for each alert, the first one
+	// present only. Defaults to summary and description.
+	Annotations []string `yaml:",omitempty"`
+	// Separator is written between the labels of an alert. Defaults to a
+	// space.
+	Separator string `yaml:",omitempty"`
+	// MaxAlerts is the number of alerts listed, the others being counted in
+	// a "+N more" line of their group. Defaults to 5, -1 lists every alert.
+	MaxAlerts int `yaml:"max_alerts,omitempty"`
+	// Severity prefixes alerts with the value of their severity label.
+	Severity bool `yaml:",omitempty"`
+	// StartTime suffixes firing alerts with the time they started at,
+	// formatted with TimeFormat, by default the one of the locale of the
+	// recipients, or Jan 2 15:04 MST.
+	StartTime  bool   `yaml:"start_time,omitempty"`
+	TimeFormat string `yaml:"time_format,omitempty"`
+}
+
+// withDefaults returns conf with its unset options set to their default.
+func (conf AlertTextConf) withDefaults() AlertTextConf {
+	if len(conf.Labels) == 0 {
+		conf.Labels = []string{"alertname", "instance", "exported_instance"}
+	}
+	if len(conf.Annotations) == 0 {
+		conf.Annotations = []string{"summary", "description"}
+	}
+	if conf.Separator == "" {
+		conf.Separator = " "
+	}
+	if conf.MaxAlerts == 0 {
+		conf.MaxAlerts = 5
+	}
+	return conf
+}
+
+func (conf AlertTextConf) validate() error {
+	if conf.MaxAlerts < -1 {
+		return fmt.Errorf("max_alerts must be -1 or more, not %d", conf.MaxAlerts)
+	}
+	return nil
+}
+
+// newAlertText returns the text of n laid out as conf says, in locale l: the
+// firing alerts under a FIRING:<count> line, then the resolved ones under a
+// RESOLVED:<count> line. The alerts of a group that are not listed are
+// counted in a "+N more" line closing the group, those Alertmanager
+// truncated in the one of the last group.
+func newAlertText(conf AlertTextConf, n notification, l *locale) string {
+	conf = conf.withDefaults()
+	data := n.Data
+
+	if len(data.Alerts) == 0 {
+		status := l.translate(strings.ToUpper(data.Status))
+		if status == "" {
+			status = l.translate("ALERT")
+		}
+		return status + "\n" + strings.Join(data.CommonLabels.Values(), conf.Separator)
+	}
+
+	// Alerts without a status are listed as firing rather than dropped.
+	var firing, resolved template.Alerts
+	for _, a := range data.Alerts {
+		if a.Status == "resolved" {
+			resolved = append(resolved, a)
+		} else {
+			firing = append(firing, a)
+		}
+	}
+	groups := []struct {
+		status string
+		alerts template.Alerts
+	}{
+		{"FIRING", firing},
+		{"RESOLVED", resolved},
+	}
+	if len(resolved) == 0 {
+		groups = groups[:1]
+	}
+
+	var b strings.Builder
+	listed := 0
+	for i, group := range groups {
+		if len(group.alerts) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s:%d", l.translate(group.status), len(group.alerts))
+		more := len(group.alerts)
+		for _, a := range group.alerts {
+			if conf.MaxAlerts >= 0 && listed == conf.MaxAlerts {
+				break
+			}
+			b.WriteString("\n" + conf.alertLine(a, l))
+			listed++
+			more--
+		}
+		if i == len(groups)-1 {
+			more += int(n.TruncatedAlerts)
+		}
+		if more > 0 {
+			b.WriteString("\n" + fmt.Sprintf(l.translate("+%d more"), more))
+		}
+	}
+	return b.String()
+}
+
+// alertLine returns the line of a in locale l.
+func (conf AlertTextConf) alertLine(a template.Alert, l *locale) string {
+	var parts []string
+	for _, name := range conf.Labels {
+		if v := a.Labels[name]; v != "" {
+			parts = append(parts, v)
+		}
+	}
+	var annotation string
+	for _, name := range conf.Annotations {
+		if annotation = a.Annotations[name]; annotation != "" {
+			break
+		}
+	}
+	// Alerts without any of the labels or annotations are written with
+	// all their labels, rather than as an empty line.
+	if len(parts) == 0 && annotation == "" {
+		for _, p := range a.Labels.SortedPairs() {
+			parts = append(parts, p.Name+"="+p.Value)
+		}
+	}
+
+	line := strings.Join(parts, conf.Separator)
+	if annotation != "" {
+		if line != "" {
+			line += ": "
+		}
+		line += annotation
+	}
+	if severity := a.Labels["severity"]; conf.Severity && severity != "" {
+		line = "[" + severity + "] " + line
+	}
+	if conf.StartTime && a.Status == "firing" && !a.StartsAt.IsZero() {
+		line += " (" + fmt.Sprintf(l.translate("since %s"), l.formatTime(a.StartsAt, conf.TimeFormat)) + ")"
+	}
+	return line
+}
+
+// alertTextFor returns the layout of the text of rc, its own or else the
+// global one.
+func (c *Config) alertTextFor(rc *ReceiverConf) AlertTextConf {
+	if rc.AlertText != nil {
+		return *rc.AlertText
+	}
+	return c.AlertText
+}
+
+// validateAlertText checks the global layout and those of the receivers.
+func (c *Config) validateAlertText() error {
+	if err := c.AlertText.validate(); err != nil {
+		return fmt.Errorf("alert_text: %w", err)
+	}
+	for _, rc := range c.Receivers {
+		if rc.AlertText == nil {
+			continue
+		}
+		if err := rc.AlertText.validate(); err != nil {
+			return fmt.Errorf("receiver %q: alert_text: %w", rc.Name, err)
+		}
+	}
+	return nil
+}
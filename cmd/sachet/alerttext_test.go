@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateAlertText(t *testing.T) {
+	t.Parallel()
+
+	c := Config{Receivers: []ReceiverConf{{Name: "sms", AlertText: &AlertTextConf{MaxAlerts: -2}}}}
+	assert.EqualError(t, c.validateAlertText(), `receiver "sms": alert_text: max_alerts must be -1 or more, not -2`)
+
+	c.Receivers[0].AlertText.MaxAlerts = -1
+	assert.NoError(t, c.validateAlertText())
+	assert.Equal(t, -1, c.alertTextFor(&c.Receivers[0]).MaxAlerts)
+}
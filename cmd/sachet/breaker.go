@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/messagebird/sachet"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = time.Minute
+)
+
+// CircuitBreakerConf configures the circuit breakers that stop sending
+// through a provider after it failed repeatedly.
+type CircuitBreakerConf struct {
+	// Disabled sends every message, however often the provider failed.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit. Defaults to 5.
+	FailureThreshold int `yaml:"failure_threshold,omitempty"`
+	// OpenDuration is how long an open circuit fails sends before letting a
+	// probe through. Defaults to 1m.
+	OpenDuration time.Duration `yaml:"open_duration,omitempty"`
+}
+
+func (c CircuitBreakerConf) failureThreshold() int {
+	if c.FailureThreshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+func (c CircuitBreakerConf) openDuration() time.Duration {
+	if c.OpenDuration <= 0 {
+		return defaultOpenDuration
+	}
+	return c.OpenDuration
+}
+
+// breakerState is the state of a circuit breaker, as exported in the
+// sachet_provider_circuit_breaker_state gauge.
+type breakerState int
+
+const (
+	// breakerClosed lets every send through.
+	breakerClosed breakerState = iota
+	// breakerHalfOpen lets a single probe through, which closes the circuit
+	// if it succeeds and opens it again if it fails.
+	breakerHalfOpen
+	// breakerOpen fails every send without calling the provider.
+	breakerOpen
+)
+
+// errCircuitOpen is returned for sends rejected by an open circuit breaker.
+var errCircuitOpen = errors.New("circuit breaker open after repeated failures, not sending")
+
+// circuitBreaker tracks the failures of a provider. Breakers are kept by
+// provider name across reloads.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	name     string
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// generation changes with the state, so that late results of sends
+	// allowed in an earlier state are ignored.
+	generation uint64
+	now        func() time.Time
+}
+
+var breakers = struct {
+	sync.Mutex
+	byName map[string]*circuitBreaker
+}{byName: map[string]*circuitBreaker{}}
+
+// breakerFor returns the circuit breaker of the provider with that name.
+func breakerFor(name string) *circuitBreaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.byName[name]
+	if !ok {
+		b = &circuitBreaker{name: name, now: time.Now}
+		breakers.byName[name] = b
+		providerCircuitBreakerState.WithLabelValues(name).Set(float64(breakerClosed))
+	}
+	return b
+}
+
+// sendMessage sends message through the provider with that name, unless its
+// rate limit is exceeded or its circuit breaker is open, and returns the
+// result for every recipient.
+func sendMessage(ctx context.Context, c *loadedConfig, name string, provider sachet.Provider, message sachet.Message) (results []sachet.Result, err error) {
+	ctx, span := tracer.Start(ctx, "send", trace.WithAttributes(
+		attribute.String("sachet.provider", name),
+		attribute.Int("sachet.recipients", len(message.To)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if limit, ok := c.config.RateLimits[name]; ok {
+		if err := rateLimiterFor(name).allow(limit); err != nil {
+			return nil, err
+		}
+	}
+
+	conf := c.config.CircuitBreaker
+	var (
+		b   *circuitBreaker
+		gen uint64
+	)
+	if !conf.Disabled {
+		b = breakerFor(name)
+		if gen, err = b.allow(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	results, err = deliver(ctx, provider, message)
+	observeSend(name, start, err)
+	if b != nil {
+		b.record(gen, !providerFailed(results, err), conf)
+	}
+	return results, err
+}
+
+// providerFailed reports whether a send failed because of the provider,
+// rather than because the gateway rejected the message for some recipients.
+func providerFailed(results []sachet.Result, err error) bool {
+	if err == nil {
+		return false
+	}
+	if len(results) == 0 {
+		return !sachet.IsRejected(err)
+	}
+	for _, result := range results {
+		if result.Err != nil && !sachet.IsRejected(result.Err) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver sends message through provider, making its requests with ctx if
+// the provider supports it, and returns the result for every recipient. The
+// error is nil only if the message was sent to all recipients.
+func deliver(ctx context.Context, provider sachet.Provider, message sachet.Message) ([]sachet.Result, error) {
+	var err error
+	switch p := provider.(type) {
+	case sachet.ResultProvider:
+		var results []sachet.Result
+		if results, err = p.SendResults(ctx, message); err == nil {
+			return results, sachet.ResultsError(results)
+		}
+	case sachet.ContextProvider:
+		err = p.SendContext(ctx, message)
+	default:
+		err = provider.Send(message)
+	}
+
+	results := make([]sachet.Result, len(message.To))
+	for i, recipient := range message.To {
+		results[i] = sachet.Result{Recipient: recipient, Err: err}
+	}
+	return results, err
+}
+
+// allow returns errCircuitOpen unless a send may go through, and otherwise
+// the generation to record its outcome with.
+func (b *circuitBreaker) allow(conf CircuitBreakerConf) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < conf.openDuration() {
+			return 0, errCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+	case breakerHalfOpen:
+		if b.probing {
+			return 0, errCircuitOpen
+		}
+		b.probing = true
+	}
+	return b.generation, nil
+}
+
+// record updates the breaker with the outcome of a send it allowed in
+// generation gen. Outcomes of sends allowed before the state last changed are
+// ignored, so only the probe decides whether a half-open circuit closes.
+func (b *circuitBreaker) record(gen uint64, success bool, conf CircuitBreakerConf) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen != b.generation {
+		return
+	}
+	b.probing = false
+	if success {
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= conf.failureThreshold() {
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state breakerState) {
+	if state != b.state {
+		b.generation++
+	}
+	b.state = state
+	providerCircuitBreakerState.WithLabelValues(b.name).Set(float64(state))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+func Test_circuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := &circuitBreaker{name: "test", now: func() time.Time { return now }}
+	conf := CircuitBreakerConf{FailureThreshold: 2, OpenDuration: time.Minute}
+	state := func() float64 { return testutil.ToFloat64(providerCircuitBreakerState.WithLabelValues("test")) }
+	allow := func() uint64 {
+		gen, err := b.allow(conf)
+		assert.NoError(t, err)
+		return gen
+	}
+
+	// Failures below the threshold keep the circuit closed.
+	b.record(allow(), false, conf)
+	stale := allow()
+	b.record(allow(), false, conf)
+	assert.Equal(t, float64(breakerOpen), state())
+
+	// An open circuit fails fast until the open duration passed.
+	_, err := b.allow(conf)
+	assert.ErrorIs(t, err, errCircuitOpen)
+	now = now.Add(time.Minute)
+
+	// Then a single probe goes through, and reopens the circuit if it fails.
+	probe := allow()
+	assert.Equal(t, float64(breakerHalfOpen), state())
+	_, err = b.allow(conf)
+	assert.ErrorIs(t, err, errCircuitOpen, "second probe")
+
+	// Late results of sends allowed before the circuit opened are ignored.
+	b.record(stale, true, conf)
+	assert.Equal(t, float64(breakerHalfOpen), state())
+
+	b.record(probe, false, conf)
+	assert.Equal(t, float64(breakerOpen), state())
+	_, err = b.allow(conf)
+	assert.ErrorIs(t, err, errCircuitOpen)
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	b.record(allow(), true, conf)
+	assert.Equal(t, float64(breakerClosed), state())
+	allow()
+}
+
+func Test_providerFailed(t *testing.T) {
+	t.Parallel()
+
+	rejected := sachet.Rejected(errors.New("invalid number"))
+	cases := []struct {
+		name    string
+		results []sachet.Result
+		err     error
+		want    bool
+	}{
+		{"sent", []sachet.Result{{Recipient: "1"}}, nil, false},
+		{"failed", []sachet.Result{{Recipient: "1", Err: assert.AnError}}, assert.AnError, true},
+		{"rejected", []sachet.Result{{Recipient: "1"}, {Recipient: "2", Err: rejected}}, sachet.ResultsError([]sachet.Result{{Recipient: "2", Err: rejected}}), false},
+		{"rejected and failed", []sachet.Result{{Recipient: "1", Err: assert.AnError}, {Recipient: "2", Err: rejected}}, assert.AnError, true},
+		{"no results", nil, rejected, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, providerFailed(tc.results, tc.err))
+		})
+	}
+}
+
+func Test_deliver_context(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Result providers get the context too, so cancelled sends stop.
+	results, err := deliver(ctx, resultProvider{}, sachet.Message{To: []string{"+31612345678"}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []sachet.Result{{Recipient: "+31612345678", Err: context.Canceled}}, results)
+}
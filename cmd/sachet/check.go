@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"gopkg.in/yaml.v2"
+
+	"github.com/messagebird/sachet"
+)
+
+// checkConfig validates the configuration file without starting the server
+// and writes a report to w. It returns false if any problem was found.
+func checkConfig(w io.Writer, filename string) bool {
+	fmt.Fprintf(w, "Checking %s\n", filename)
+
+	config, problems := configProblems(filename)
+	if len(problems) > 0 {
+		fmt.Fprintf(w, "  FAILED: %d problem(s) found\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(w, "  - %s\n", problem)
+		}
+		return false
+	}
+
+	fmt.Fprintf(w, "  SUCCESS: %d receiver(s), %d template glob(s)\n", len(config.Receivers), len(config.Templates))
+	return true
+}
+
+// configProblems loads filename the same way LoadConfig does, but collects
+// every problem instead of stopping at the first one. Unknown keys are
+// reported as problems as well. It never changes the configuration in use.
+func configProblems(filename string) (config Config, problems []string) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, []string{err.Error()}
+	}
+
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		// A TypeError holds one message per offending key, the rest of the
+		// document is still decoded.
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return config, []string{err.Error()}
+		}
+		problems = append(problems, typeErr.Errors...)
+	}
+
+	if err := sachet.ResolveSecrets(&config.Providers); err != nil {
+		problems = append(problems, fmt.Sprintf("providers.%s", err))
+	}
+
+	if err := config.HealthChecks.validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := config.validateAlertText(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := config.validateLocales(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := config.validateDefaultReceiver(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := config.validateRateLimits(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := config.loadInputs(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	tmpl, err := newTemplates(config.Templates...)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("templates: %s", err))
+		tmpl = nil
+	}
+	var receiverTmpls map[string]*templates
+	if tmpl != nil {
+		if receiverTmpls, err = config.receiverTemplates(tmpl); err != nil {
+			problems = append(problems, err.Error())
+			tmpl = nil
+		}
+	}
+
+	loaded := map[string]sachet.Provider{}
+	seen := map[string]bool{}
+	for i := range config.Receivers {
+		rc := &config.Receivers[i]
+
+		if seen[rc.Name] {
+			problems = append(problems, fmt.Sprintf("receiver %q: defined more than once", rc.Name))
+		}
+		seen[rc.Name] = true
+
+		if !config.providerConfigured(rc.Provider) {
+			problems = append(problems, fmt.Sprintf("receiver %q: provider %q is not configured", rc.Name, rc.Provider))
+		}
+		if err := config.loadReceiver(rc, loaded); err != nil {
+			problems = append(problems, err.Error())
+		}
+
+		if tmpl != nil {
+			rt, ok := receiverTmpls[rc.Name]
+			if !ok {
+				rt = tmpl
+			}
+			texts := []struct{ key, text string }{
+				{"text", rc.Text},
+				{"text_firing", rc.TextFiring},
+				{"text_resolved", rc.TextResolved},
+				{"subject", rc.Subject},
+			}
+			// Templates are rendered in every locale of the recipients, as
+			// each can have templates of its own.
+			var locales []*locale
+			for _, a := range rc.audiences() {
+				if l, err := config.newLocale(a.locale, a.timeZone); err == nil {
+					locales = append(locales, l)
+				}
+			}
+			for _, t := range texts {
+				if t.text == "" {
+					continue
+				}
+				for _, l := range locales {
+					if _, err := rt.execute(t.text, sampleData(rc.Name), l); err != nil {
+						problems = append(problems, fmt.Sprintf("receiver %q: %s: %s", rc.Name, t.key, err))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return config, problems
+}
+
+// providerConfigured reports whether the providers section sets any option
+// for the provider with that name.
+func (c *Config) providerConfigured(name string) bool {
+	v := reflect.ValueOf(c.Providers)
+	for i := 0; i < v.NumField(); i++ {
+		if key, _, _ := yamlKey(v.Type().Field(i)); key == name {
+			return !v.Field(i).IsZero()
+		}
+	}
+	return false
+}
+
+// sampleData returns a notification for receiver with one firing and one
+// resolved alert, as Alertmanager would send it.
+func sampleData(receiver string) notification {
+	now := time.Now()
+
+	return notification{Version: "4", GroupKey: `{}:{alertname="InstanceDown"}`, Data: template.Data{
+		Receiver: receiver,
+		Status:   "firing",
+		Alerts: template.Alerts{
+			{
+				Status: "firing",
+				Labels: template.KV{
+					"alertname": "InstanceDown",
+					"instance":  "node-1:9100",
+					"job":       "node",
+					"severity":  "critical",
+				},
+				Annotations: template.KV{
+					"summary":     "node-1:9100 is down",
+					"description": "node-1:9100 of job node has been down for more than 5 minutes.",
+				},
+				StartsAt:     now.Add(-10 * time.Minute),
+				GeneratorURL: "http://prometheus.example.com/graph?g0.expr=up+%3D%3D+0",
+				Fingerprint:  "c1b1c4b2a6d3e0f1",
+			},
+			{
+				Status: "resolved",
+				Labels: template.KV{
+					"alertname": "InstanceDown",
+					"instance":  "node-2:9100",
+					"job":       "node",
+					"severity":  "critical",
+				},
+				Annotations: template.KV{
+					"summary":     "node-2:9100 is down",
+					"description": "node-2:9100 of job node has been down for more than 5 minutes.",
+				},
+				StartsAt:     now.Add(-30 * time.Minute),
+				EndsAt:       now.Add(-time.Minute),
+				GeneratorURL: "http://prometheus.example.com/graph?g0.expr=up+%3D%3D+0",
+				Fingerprint:  "5d8e4b0c9f2a7e13",
+			},
+		},
+		GroupLabels: template.KV{
+			"alertname": "InstanceDown",
+		},
+		CommonLabels: template.KV{
+			"alertname": "InstanceDown",
+			"job":       "node",
+			"severity":  "critical",
+		},
+		CommonAnnotations: template.KV{},
+		ExternalURL:       "http://alertmanager.example.com",
+	}}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet/provider/cm"
+)
+
+func Test_checkConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+providers:
+  telegram:
+    token: 'token'
+    pase_mode: 'Markdown'
+receivers:
+  - name: 'team-chat'
+    provider: 'telegram'
+    to: ['@me']
+    text: '{{ template "missing" . }}'
+  - name: 'team-sms'
+    provider: 'twilio'
+    to: ['+31612345678']
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var report bytes.Buffer
+	assert.False(t, checkConfig(&report, filename))
+	assert.Equal(t, `Checking `+filename+`
+  FAILED: 4 problem(s) found
+  - line 5: field pase_mode not found in type telegram.Config
+  - receiver "team-chat": provider telegram: invalid recipient "@me": chat ID must be numeric
+  - receiver "team-chat": text: template: :1:12: executing "" at <{{template "missing" .}}>: template "missing" not defined
+  - receiver "team-sms": provider "twilio" is not configured
+`, report.String())
+}
+
+func Test_LoadConfig_secretFile(t *testing.T) {
+	restoreConfig(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+	tokenFile := filepath.Join(dir, "token")
+	content := `
+providers:
+  telegram:
+    token_file: '` + tokenFile + `'
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is read again on every load, so a reload picks up a rotated token.
+	for _, token := range []string{"first", "second"} {
+		if err := ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, LoadConfig(filename))
+		assert.Equal(t, token, string(currentConfig().config.Providers.Telegram.Token))
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(content+"    token: 'inline'\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, LoadConfig(filename), "providers.telegram.token_file: cannot be set together with token")
+}
+
+func Test_loadProviders_nationalNumbers(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		country string
+		exp     string
+	}{
+		// Passed on as is, as before default_country existed.
+		{"", "0912 345 6789"},
+		{"IR", "09123456789"},
+	} {
+		c := Config{
+			DefaultCountry: tc.country,
+			Receivers:      []ReceiverConf{{Name: "team-sms", Provider: "kavenegar", To: []string{"0912 345 6789"}}},
+		}
+		_, err := c.loadProviders()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{tc.exp}, c.Receivers[0].To)
+	}
+}
+
+func Test_validateReceiver_senderID(t *testing.T) {
+	t.Parallel()
+
+	c := Config{
+		Receivers: []ReceiverConf{{Name: "team-chat", Provider: "telegram", To: []string{"164451814"}, From: "sachet"}},
+	}
+	_, err := c.loadProviders()
+	assert.NoError(t, err)
+}
+
+func Test_validateReceiver_notCapable(t *testing.T) {
+	t.Parallel()
+
+	// cm does not declare its capabilities, so its type and sender ID are
+	// not validated.
+	for _, tc := range []struct {
+		receiver ReceiverConf
+		err      string
+	}{
+		{ReceiverConf{From: "sachet"}, ""},
+		{ReceiverConf{Type: "text"}, ""},
+		{ReceiverConf{Subject: "alert"}, "subject is not supported"},
+	} {
+		tc.receiver.Name, tc.receiver.Provider, tc.receiver.To = "team-sms", "cm", []string{"+31612345678"}
+		c := Config{Receivers: []ReceiverConf{tc.receiver}}
+		_, err := c.loadProviders()
+		if tc.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorContains(t, err, tc.err)
+		}
+	}
+}
+
+func Test_loadProviders_transport(t *testing.T) {
+	var wrapped []http.RoundTripper
+	wrapProviderTransport = func(t http.RoundTripper) http.RoundTripper {
+		wrapped = append(wrapped, t)
+		return loggingTransport{t}
+	}
+	t.Cleanup(func() { wrapProviderTransport = nil })
+
+	c := Config{
+		Receivers: []ReceiverConf{
+			{Name: "team-sms", Provider: "cm", To: []string{"+31612345678"}},
+			{Name: "team-sms-2", Provider: "cm", To: []string{"+31612345678"}},
+			{Name: "team-chat", Provider: "pushbullet", To: []string{"device:phone"}},
+		},
+	}
+	providers, err := c.loadProviders()
+	assert.NoError(t, err)
+
+	// Providers are wrapped once, those without a client of their own not
+	// at all.
+	assert.Equal(t, []http.RoundTripper{http.DefaultTransport}, wrapped)
+	assert.Equal(t, loggingTransport{http.DefaultTransport}, providers["cm"].(*cm.CM).HTTPClient.Transport)
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 
-	"github.com/prometheus/alertmanager/template"
 	"gopkg.in/yaml.v2"
 
+	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 	"github.com/messagebird/sachet/provider/aliyun"
 	"github.com/messagebird/sachet/provider/aspsms"
 	"github.com/messagebird/sachet/provider/cm"
 	"github.com/messagebird/sachet/provider/esendex"
 	"github.com/messagebird/sachet/provider/exotel"
 	"github.com/messagebird/sachet/provider/freemobile"
+	"github.com/messagebird/sachet/provider/generichttp"
 	"github.com/messagebird/sachet/provider/ghasedak"
 	"github.com/messagebird/sachet/provider/infobip"
 	"github.com/messagebird/sachet/provider/kannel"
@@ -41,61 +50,406 @@ type ReceiverConf struct {
 	Name     string
 	Provider string
 	To       []string
-	From     string
-	Text     string
-	Type     string
+	From     string `yaml:",omitempty"`
+	Text     string `yaml:",omitempty"`
+	Type     string `yaml:",omitempty"`
+
+	// DefaultCountry overrides the global default country for this receiver.
+	DefaultCountry string `yaml:"default_country,omitempty"`
+
+	// TextFiring and TextResolved replace Text for firing and resolved
+	// notifications.
+	TextFiring   string `yaml:"text_firing,omitempty"`
+	TextResolved string `yaml:"text_resolved,omitempty"`
+	// Subject is the template of the title of messages, for providers that
+	// show one.
+	Subject string `yaml:",omitempty"`
+	// SendResolved can be set to false to skip notifications whose alerts
+	// are all resolved.
+	SendResolved *bool `yaml:"send_resolved,omitempty"`
+
+	// Templates are globs of template files available to this receiver only.
+	Templates []string `yaml:",omitempty"`
+	// AlertText replaces the global layout of the text sent without a text
+	// template.
+	AlertText *AlertTextConf `yaml:"alert_text,omitempty"`
+
+	// Locale is the BCP 47 language tag, such as fr, messages are rendered
+	// in, and TimeZone the IANA time zone, such as Europe/Paris, of their
+	// times. Contacts are recipients with a locale or time zone of their own.
+	Locale   string        `yaml:",omitempty"`
+	TimeZone string        `yaml:"time_zone,omitempty"`
+	Contacts []ContactConf `yaml:",omitempty"`
+}
+
+// textTemplate returns the template of the text of notifications with the
+// given status.
+func (rc *ReceiverConf) textTemplate(status string) string {
+	switch {
+	case status == "resolved" && rc.TextResolved != "":
+		return rc.TextResolved
+	case status != "resolved" && rc.TextFiring != "":
+		return rc.TextFiring
+	}
+	return rc.Text
+}
+
+// sendsResolved reports whether rc sends notifications whose alerts are all
+// resolved.
+func (rc *ReceiverConf) sendsResolved() bool {
+	return rc.SendResolved == nil || *rc.SendResolved
 }
 
-var config struct {
+// Config is the structure of the configuration file.
+type Config struct {
 	Providers struct {
-		MessageBird  messagebird.Config
-		Nexmo        nexmo.Config
-		Twilio       twilio.Config
-		Infobip      infobip.Config
-		Kannel       kannel.Config
-		KaveNegar    kavenegar.Config
-		Exotel       exotel.Config
-		CM           cm.Config
-		MailruIM     mailruim.Config
-		Telegram     telegram.Config
-		Turbosms     turbosms.Config
-		Smsc         smsc.Config
-		OTC          otc.Config
-		MediaBurst   mediaburst.Config
-		FreeMobile   freemobile.Config
-		AspSms       aspsms.Config
-		Sipgate      sipgate.Config
-		Pushbullet   pushbullet.Config
-		NowSms       nowsms.Config
-		Aliyun       aliyun.Config
-		OVH          ovh.Config
-		TencentCloud tencentcloud.Config
-		Sap          sap.Config
-		Esendex      esendex.Config
-		Sms77        sms77.Config
-		Ghasedak     ghasedak.Config
-		Sfr          sfr.Config
-		TextMagic    textmagic.Config
-		Melipayamak  melipayamak.Config
+		MessageBird  messagebird.Config  `yaml:"messagebird,omitempty"`
+		Nexmo        nexmo.Config        `yaml:"nexmo,omitempty"`
+		Twilio       twilio.Config       `yaml:"twilio,omitempty"`
+		Infobip      infobip.Config      `yaml:"infobip,omitempty"`
+		Kannel       kannel.Config       `yaml:"kannel,omitempty"`
+		KaveNegar    kavenegar.Config    `yaml:"kavenegar,omitempty"`
+		Exotel       exotel.Config       `yaml:"exotel,omitempty"`
+		CM           cm.Config           `yaml:"cm,omitempty"`
+		MailruIM     mailruim.Config     `yaml:"mailruim,omitempty"`
+		Telegram     telegram.Config     `yaml:"telegram,omitempty"`
+		Turbosms     turbosms.Config     `yaml:"turbosms,omitempty"`
+		Smsc         smsc.Config         `yaml:"smsc,omitempty"`
+		OTC          otc.Config          `yaml:"otc,omitempty"`
+		MediaBurst   mediaburst.Config   `yaml:"mediaburst,omitempty"`
+		FreeMobile   freemobile.Config   `yaml:"freemobile,omitempty"`
+		AspSms       aspsms.Config       `yaml:"aspsms,omitempty"`
+		Sipgate      sipgate.Config      `yaml:"sipgate,omitempty"`
+		Pushbullet   pushbullet.Config   `yaml:"pushbullet,omitempty"`
+		NowSms       nowsms.Config       `yaml:"nowsms,omitempty"`
+		Aliyun       aliyun.Config       `yaml:"aliyun,omitempty"`
+		OVH          ovh.Config          `yaml:"ovh,omitempty"`
+		TencentCloud tencentcloud.Config `yaml:"tencentcloud,omitempty"`
+		Sap          sap.Config          `yaml:"sap,omitempty"`
+		Esendex      esendex.Config      `yaml:"esendex,omitempty"`
+		Sms77        sms77.Config        `yaml:"sms77,omitempty"`
+		Ghasedak     ghasedak.Config     `yaml:"ghasedak,omitempty"`
+		Sfr          sfr.Config          `yaml:"sfr,omitempty"`
+		TextMagic    textmagic.Config    `yaml:"textmagic,omitempty"`
+		Melipayamak  melipayamak.Config  `yaml:"melipayamak,omitempty"`
+		HTTP         generichttp.Config  `yaml:"http,omitempty"`
 	}
 
 	Receivers []ReceiverConf
 	Templates []string
+
+	// ProviderTemplates are globs of template files available to the
+	// receivers of a provider only, by provider name.
+	ProviderTemplates map[string][]string `yaml:"provider_templates,omitempty"`
+	// AlertText is the layout of the text of receivers without a text
+	// template.
+	AlertText AlertTextConf `yaml:"alert_text,omitempty"`
+	// Translations are the translations of the strings templates pass to tr,
+	// by locale.
+	Translations map[string]map[string]string `yaml:"translations,omitempty"`
+
+	// DefaultCountry is the ISO 3166-1 alpha-2 code of the country assumed for
+	// phone numbers written without an international prefix.
+	DefaultCountry string `yaml:"default_country,omitempty"`
+
+	HealthChecks   HealthCheckConf    `yaml:"health_checks,omitempty"`
+	CircuitBreaker CircuitBreakerConf `yaml:"circuit_breaker,omitempty"`
+	// RateLimits limit the messages sent through providers, by name.
+	RateLimits map[string]RateLimitConf `yaml:"rate_limits,omitempty"`
+
+	// DefaultReceiver is the receiver of the notifications whose receiver is
+	// not configured, which are refused otherwise.
+	DefaultReceiver string `yaml:"default_receiver,omitempty"`
+
+	// JSONInputs are the generic JSON webhook inputs served at /input/<name>.
+	JSONInputs []JSONInputConf `yaml:"json_inputs,omitempty"`
+}
+
+// loadedConfig is a configuration file together with the templates and
+// providers built from it. It is never modified once loaded, so requests keep
+// using the one they started with while a reload builds its replacement.
+type loadedConfig struct {
+	config    Config
+	tmpl      *templates
+	providers map[string]sachet.Provider
+	inputs    map[string]inputAdapter
+
+	// receiverTmpls are the templates of the receivers that have more than
+	// tmpl, by receiver name.
+	receiverTmpls map[string]*templates
+
+	// secrets replaces the credentials of the providers in logged text.
+	secrets *strings.Replacer
 }
-var tmpl *template.Template
 
-// LoadConfig loads the specified YAML configuration file.
+var (
+	// current holds the *loadedConfig in use.
+	current atomic.Value
+	// reloadMu serialises reloads triggered at the same time.
+	reloadMu sync.Mutex
+)
+
+// currentConfig returns the configuration in use.
+func currentConfig() *loadedConfig {
+	c, _ := current.Load().(*loadedConfig)
+	if c == nil {
+		return &loadedConfig{}
+	}
+	return c
+}
+
+// LoadConfig loads the specified YAML configuration file and, if it is valid
+// as a whole, replaces the configuration in use with it. The configuration in
+// use is kept if loading fails.
 func LoadConfig(filename string) error {
-	content, err := ioutil.ReadFile(filename)
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	c, err := loadConfig(filename)
 	if err != nil {
+		configLastReloadSuccessful.Set(0)
 		return err
 	}
 
-	err = yaml.Unmarshal(content, &config)
+	current.Store(c)
+	requestHealthCheck()
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// loadConfig reads filename and builds its templates and providers.
+func loadConfig(filename string) (*loadedConfig, error) {
+	content, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	c := &loadedConfig{}
+	if err = yaml.UnmarshalStrict(content, &c.config); err != nil {
+		return nil, err
+	}
+
+	if err = sachet.ResolveSecrets(&c.config.Providers); err != nil {
+		return nil, fmt.Errorf("providers.%w", err)
+	}
+	c.secrets = newSecretReplacer(sachet.SecretValues(&c.config.Providers))
+	if c.config.Providers.MessageBird.Debug || c.config.Providers.Sms77.Debug {
+		slog.Warn("The debug option of the messagebird and sms77 providers is deprecated and ignored, use -log-level=debug instead")
+	}
+
+	if err = c.config.HealthChecks.validate(); err != nil {
+		return nil, err
+	}
+
+	if err = c.config.validateAlertText(); err != nil {
+		return nil, err
+	}
+	if err = c.config.validateLocales(); err != nil {
+		return nil, err
+	}
+	if err = c.config.validateDefaultReceiver(); err != nil {
+		return nil, err
+	}
+	if err = c.config.validateRateLimits(); err != nil {
+		return nil, err
+	}
+
+	if c.inputs, err = c.config.loadInputs(); err != nil {
+		return nil, err
+	}
+
+	if c.tmpl, err = newTemplates(c.config.Templates...); err != nil {
+		return nil, err
+	}
+	if c.receiverTmpls, err = c.config.receiverTemplates(c.tmpl); err != nil {
+		return nil, err
+	}
+
+	if c.providers, err = c.config.loadProviders(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validateDefaultReceiver checks that the default receiver is configured.
+func (c *Config) validateDefaultReceiver() error {
+	if c.DefaultReceiver != "" && c.receiverConfByReceiver(c.DefaultReceiver) == nil {
+		return fmt.Errorf("default_receiver: receiver %q missing", c.DefaultReceiver)
+	}
+	return nil
+}
+
+// receiverTemplates returns the templates of the receivers that add their
+// own or their provider's to t, by receiver name.
+func (c *Config) receiverTemplates(t *templates) (map[string]*templates, error) {
+	byProvider := map[string]*templates{}
+	byReceiver := map[string]*templates{}
+	for i := range c.Receivers {
+		rc := &c.Receivers[i]
+		pt, ok := byProvider[rc.Provider]
+		if !ok {
+			var err error
+			if pt, err = t.with(rc.Provider, c.ProviderTemplates[rc.Provider]...); err != nil {
+				return nil, fmt.Errorf("provider_templates.%s: %w", rc.Provider, err)
+			}
+			byProvider[rc.Provider] = pt
+		}
+
+		rt, err := pt.with("", rc.Templates...)
+		if err != nil {
+			return nil, fmt.Errorf("receiver %q: templates: %w", rc.Name, err)
+		}
+		if rt != t {
+			byReceiver[rc.Name] = rt
+		}
+	}
+	return byReceiver, nil
+}
+
+// templatesFor returns the templates receiverConf renders messages with.
+func (c *loadedConfig) templatesFor(receiverConf *ReceiverConf) *templates {
+	if t, ok := c.receiverTmpls[receiverConf.Name]; ok {
+		return t
+	}
+	return c.tmpl
+}
+
+// redact replaces the provider credentials in s with a placeholder.
+func (c *loadedConfig) redact(s string) string {
+	if c.secrets == nil {
+		return s
+	}
+	return c.secrets.Replace(s)
+}
+
+// configYAML returns the effective configuration as YAML, with secrets redacted.
+func configYAML() ([]byte, error) {
+	return yaml.Marshal(currentConfig().config)
+}
+
+// loadProviders creates the providers used by the configured receivers and
+// validates the recipients of every receiver against its provider. Every
+// provider is created once and shared by concurrent requests.
+func (c *Config) loadProviders() (map[string]sachet.Provider, error) {
+	loaded := map[string]sachet.Provider{}
+	for i := range c.Receivers {
+		if err := c.loadReceiver(&c.Receivers[i], loaded); err != nil {
+			return nil, err
+		}
+	}
+	return loaded, nil
+}
+
+// wrapProviderTransport instruments the requests of the providers sending
+// them with their own client, to log and trace them. It is nil unless Sachet
+// serves notifications.
+var wrapProviderTransport func(http.RoundTripper) http.RoundTripper
+
+// loadReceiver creates the provider of rc unless loaded already holds it, and
+// validates rc against that provider.
+func (c *Config) loadReceiver(rc *ReceiverConf, loaded map[string]sachet.Provider) error {
+	provider, ok := loaded[rc.Provider]
+	if !ok {
+		var err error
+		if provider, err = c.newProvider(rc); err != nil {
+			return err
+		}
+		loaded[rc.Provider] = provider
 	}
 
-	tmpl, err = template.FromGlobs(config.Templates...)
-	return err
+	if err := c.normalizeRecipients(rc, provider); err != nil {
+		return fmt.Errorf("receiver %q: %w", rc.Name, err)
+	}
+	return checkReceiver(rc, provider)
+}
+
+// newProvider creates the provider of rc, with its transport instrumented.
+func (c *Config) newProvider(rc *ReceiverConf) (sachet.Provider, error) {
+	provider, err := c.providerByName(rc.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("receiver %q: %w", rc.Name, err)
+	}
+	if p, ok := provider.(sachet.HTTPProvider); ok && wrapProviderTransport != nil {
+		p.WrapTransport(wrapProviderTransport)
+	}
+	return provider, nil
+}
+
+// checkReceiver is validateReceiver with the receiver named in its error.
+func checkReceiver(rc *ReceiverConf, provider sachet.Provider) error {
+	if err := validateReceiver(rc, provider); err != nil {
+		return fmt.Errorf("receiver %q: provider %s: %w", rc.Name, rc.Provider, err)
+	}
+	return nil
+}
+
+// validateReceiver checks rc against the capabilities its provider declares.
+func validateReceiver(rc *ReceiverConf, provider sachet.Provider) error {
+	p, ok := provider.(sachet.CapableProvider)
+	if !ok {
+		// Providers that do not declare their capabilities are passed the
+		// type and sender ID as they were before, but none shows a subject.
+		if rc.Subject != "" {
+			return errors.New("subject is not supported")
+		}
+		return nil
+	}
+
+	caps := p.Capabilities()
+	from := rc.From
+	if from != "" && !caps.SenderID {
+		// Receivers could set from for every provider before capabilities
+		// were declared, so an ignored sender ID is not an error.
+		slog.Warn("The provider ignores the sender ID of the receiver", "receiver", rc.Name, "provider", rc.Provider, "from", from)
+		from = ""
+	}
+	return caps.Validate(sachet.Message{
+		To:      rc.recipients(),
+		From:    from,
+		Type:    rc.Type,
+		Subject: rc.Subject,
+	})
+}
+
+// normalizeRecipients rewrites the recipients of rc in the phone number format
+// its provider expects. Providers that do not address recipients by phone
+// number are left alone.
+func (c *Config) normalizeRecipients(rc *ReceiverConf, provider sachet.Provider) error {
+	p, ok := provider.(sachet.PhoneNumberProvider)
+	if !ok {
+		return nil
+	}
+
+	country := rc.DefaultCountry
+	if country == "" {
+		country = c.DefaultCountry
+	}
+	for i, to := range rc.To {
+		number, err := normalizeNumber(rc, to, country, p.PhoneNumberFormat())
+		if err != nil {
+			return err
+		}
+		rc.To[i] = number
+	}
+	for i, contact := range rc.Contacts {
+		number, err := normalizeNumber(rc, contact.To, country, p.PhoneNumberFormat())
+		if err != nil {
+			return err
+		}
+		rc.Contacts[i].To = number
+	}
+	return nil
+}
+
+// normalizeNumber normalises a recipient of rc. Numbers without an
+// international prefix were passed on as is before default_country existed,
+// so without a default country they still are, with a warning.
+func normalizeNumber(rc *ReceiverConf, number, country string, f phonenumber.Format) (string, error) {
+	normalized, err := phonenumber.Normalize(number, country, f)
+	if errors.Is(err, phonenumber.ErrNoDefaultCountry) {
+		slog.Warn("Recipients without an international prefix are deprecated without default_country and are not validated", "receiver", rc.Name, "to", number)
+		return number, nil
+	}
+	return normalized, err
 }
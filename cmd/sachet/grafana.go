@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// grafanaNotification is the payload of the Grafana webhook contact point.
+// It extends the Alertmanager webhook payload, so receiver templates written
+// for Alertmanager work with it, and can use the Grafana fields too.
+type grafanaNotification struct {
+	notification
+
+	// Alerts shadows the Alertmanager alerts of Data, which it is copied to.
+	Alerts  grafanaAlerts `json:"alerts"`
+	Title   string        `json:"title"`
+	State   string        `json:"state"`
+	Message string        `json:"message"`
+	OrgID   int64         `json:"orgId"`
+}
+
+// grafanaAlert is an alert of a Grafana notification.
+type grafanaAlert struct {
+	template.Alert
+
+	// Values are the values of the queries and expressions of the alert
+	// rule, by reference ID.
+	Values       map[string]float64 `json:"values"`
+	SilenceURL   string             `json:"silenceURL"`
+	DashboardURL string             `json:"dashboardURL"`
+	PanelURL     string             `json:"panelURL"`
+	ImageURL     string             `json:"imageURL"`
+}
+
+// grafanaAlerts is a list of Grafana alerts, with the methods of
+// template.Alerts.
+type grafanaAlerts []grafanaAlert
+
+// Firing returns the subset of alerts that are firing.
+func (as grafanaAlerts) Firing() grafanaAlerts {
+	return as.withStatus("firing")
+}
+
+// Resolved returns the subset of alerts that are resolved.
+func (as grafanaAlerts) Resolved() grafanaAlerts {
+	return as.withStatus("resolved")
+}
+
+func (as grafanaAlerts) withStatus(status string) grafanaAlerts {
+	res := grafanaAlerts{}
+	for _, a := range as {
+		if a.Status == status {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// Grafana sends the notifications of the Grafana webhook contact point
+// through their receiver, which is the name of the contact point unless the
+// receiver query parameter names another one.
+func (h handlers) Grafana(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var n grafanaNotification
+	_, span := tracer.Start(r.Context(), "decode")
+	err := json.NewDecoder(r.Body).Decode(&n)
+	if err != nil {
+		err = decodeError(err)
+	}
+	endSpan(span, err)
+	if err != nil {
+		errorHandler(w, r, errorStatus(err), err, "?")
+		return
+	}
+
+	if receiver := r.URL.Query().Get("receiver"); receiver != "" {
+		n.Receiver = receiver
+	}
+	n.Data.Alerts = make(template.Alerts, len(n.Alerts))
+	for i, a := range n.Alerts {
+		n.Data.Alerts[i] = a.Alert
+	}
+
+	notify(w, r, n.notification, n)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+const grafanaPayload = `{
+  "receiver": "grafana-sms",
+  "status": "firing",
+  "orgId": 1,
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "HighCPU", "instance": "node-1"},
+      "annotations": {"summary": "CPU is high"},
+      "values": {"B": 97.5, "C": 1},
+      "dashboardURL": "https://grafana.example.com/d/abc",
+      "panelURL": "https://grafana.example.com/d/abc?viewPanel=2"
+    },
+    {
+      "status": "resolved",
+      "labels": {"alertname": "HighCPU", "instance": "node-2"},
+      "values": {"B": 12, "C": 0}
+    }
+  ],
+  "commonLabels": {"alertname": "HighCPU"},
+  "groupKey": "{}:{alertname=\"HighCPU\"}",
+  "title": "[FIRING:1, RESOLVED:1] HighCPU",
+  "state": "alerting",
+  "message": "**Firing**"
+}`
+
+func Test_Grafana(t *testing.T) {
+	_, sent := newTestConfig(t,
+		ReceiverConf{
+			Name:     "grafana-sms",
+			Provider: "recording",
+			To:       []string{"1"},
+			Text:     `{{ .Title }} (org {{ .OrgID }}){{ range .Alerts.Firing }} {{ .Labels.instance }} B={{ .Values.B }} {{ .PanelURL }}{{ end }}`,
+		},
+		ReceiverConf{Name: "ops", Provider: "recording", To: []string{"2"}},
+	)
+
+	cases := []struct {
+		name   string
+		target string
+		status int
+		exp    sachet.Message
+	}{
+		{
+			name:   "grafana fields",
+			target: "/input/grafana",
+			status: http.StatusOK,
+			exp: sachet.Message{
+				To:   []string{"1"},
+				Text: "[FIRING:1, RESOLVED:1] HighCPU (org 1) node-1 B=97.5 https://grafana.example.com/d/abc?viewPanel=2",
+			},
+		},
+		{
+			name:   "receiver parameter with default text",
+			target: "/input/grafana?receiver=ops",
+			status: http.StatusOK,
+			exp:    sachet.Message{To: []string{"2"}, Text: "FIRING:1\nHighCPU node-1: CPU is high\nRESOLVED:1\nHighCPU node-2"},
+		},
+		{
+			name:   "unknown receiver",
+			target: "/input/grafana?receiver=dev",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range cases {
+		*sent = nil
+		w := httptest.NewRecorder()
+		handlers{}.Grafana(w, httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(grafanaPayload)))
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		if tc.status != http.StatusOK {
+			assert.Empty(t, *sent, tc.name)
+			continue
+		}
+		if !assert.Len(t, *sent, 1, tc.name) {
+			continue
+		}
+		assert.Equal(t, tc.exp, (*sent)[0], tc.name)
+	}
+}
@@ -2,109 +2,326 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"mime"
 	"net/http"
-	"sort"
 	"strings"
 
 	"github.com/prometheus/alertmanager/template"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/messagebird/sachet"
 )
 
 type handlers struct{}
 
-func newAlertText(data template.Data) string {
-	if len(data.Alerts) > 1 {
-		labelAlerts := map[string]template.Alerts{
-			"Firing":   data.Alerts.Firing(),
-			"Resolved": data.Alerts.Resolved(),
+// newMessages renders the messages receiverConf sends through provider for
+// n, one per locale of its recipients.
+func (c *loadedConfig) newMessages(receiverConf *ReceiverConf, provider sachet.Provider, n notification) ([]sachet.Message, error) {
+	return c.renderMessages(receiverConf, provider, n, n)
+}
+
+// renderMessages is newMessages for notifications whose templates are
+// rendered against tmplData, which extends n with fields of its own.
+func (c *loadedConfig) renderMessages(receiverConf *ReceiverConf, provider sachet.Provider, n notification, tmplData interface{}) ([]sachet.Message, error) {
+	var messages []sachet.Message
+	for _, a := range receiverConf.audiences() {
+		l, err := c.config.newLocale(a.locale, a.timeZone)
+		if err != nil {
+			return nil, err
 		}
-		text := ""
-		for label, alerts := range labelAlerts {
-			if len(alerts) > 0 {
-				text += label + ": \n"
-				for _, alert := range alerts {
-					text += alert.Labels["alertname"] + " @" + alert.Labels["instance"]
-					if len(alert.Labels["exported_instance"]) > 0 {
-						text += " (" + alert.Labels["exported_instance"] + ")"
-					}
-					text += "\n"
-				}
-			}
+		message, err := c.renderMessage(receiverConf, provider, n, tmplData, l)
+		if err != nil {
+			return nil, err
 		}
-		return text
+		message.To = a.to
+		messages = append(messages, message)
 	}
+	return messages, nil
+}
 
-	if len(data.Alerts) == 1 {
-		alert := data.Alerts[0]
-		tuples := []string{}
-		for k, v := range alert.Labels {
-			tuples = append(tuples, k+"= "+v)
+// renderMessage renders the message of receiverConf in locale l.
+func (c *loadedConfig) renderMessage(receiverConf *ReceiverConf, provider sachet.Provider, n notification, tmplData interface{}, l *locale) (sachet.Message, error) {
+	t := c.templatesFor(receiverConf)
+	var text string
+	if tmpl := receiverConf.textTemplate(n.Status); tmpl != "" {
+		var err error
+		if text, err = t.execute(tmpl, tmplData, l); err != nil {
+			return sachet.Message{}, err
 		}
-		sort.Strings(tuples)
-		return strings.ToUpper(data.Status) + " \n" + strings.Join(tuples, "\n")
+	} else {
+		text = newAlertText(c.config.alertTextFor(receiverConf), n, l)
 	}
+	message := newTextMessage(receiverConf, provider, text)
 
-	return "Alert \n" + strings.Join(data.CommonLabels.Values(), " | ")
+	if receiverConf.Subject != "" {
+		var err error
+		if message.Subject, err = t.execute(receiverConf.Subject, tmplData, l); err != nil {
+			return sachet.Message{}, err
+		}
+	}
+	return message, nil
+}
+
+// newTextMessage returns the message receiverConf sends through provider with
+// the given text, truncated to the length the provider accepts.
+func newTextMessage(receiverConf *ReceiverConf, provider sachet.Provider, text string) sachet.Message {
+	if p, ok := provider.(sachet.CapableProvider); ok {
+		text = p.Capabilities().Truncate(text)
+	}
+
+	return sachet.Message{
+		To:   receiverConf.recipients(),
+		From: receiverConf.From,
+		Type: receiverConf.Type,
+		Text: text,
+	}
+}
+
+// notification is the webhook payload Alertmanager posts to /alert, which
+// receiver templates are rendered against.
+type notification struct {
+	template.Data
+	Version  string `json:"version"`
+	GroupKey string `json:"groupKey"`
+	// TruncatedAlerts is the number of alerts left out of Alerts because of
+	// the max_alerts option of the Alertmanager webhook configuration.
+	TruncatedAlerts uint64 `json:"truncatedAlerts"`
 }
 
 func (h handlers) Alert(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
+	ctx := r.Context()
+
 	// https://godoc.org/github.com/prometheus/alertmanager/template#Data
-	data := template.Data{}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		errorHandler(w, http.StatusBadRequest, err, "?")
+	var n notification
+	_, span := tracer.Start(ctx, "decode")
+	err := checkContentType(r)
+	if err == nil {
+		if err = json.NewDecoder(r.Body).Decode(&n); err != nil {
+			err = decodeError(err)
+		} else {
+			err = n.validate()
+		}
+	}
+	endSpan(span, err)
+	if err != nil {
+		errorHandler(w, r, errorStatus(err), err, "?")
 		return
 	}
 
-	receiverConf := receiverConfByReceiver(data.Receiver)
+	notify(w, r, n, n)
+}
+
+// webhookVersion is the version of the Alertmanager webhook payload.
+const webhookVersion = "4"
+
+// validate checks the fields of n Alertmanager always sets. The version is
+// checked only if set, as payloads written by hand often lack it.
+func (n *notification) validate() error {
+	if n.Version != "" && n.Version != webhookVersion {
+		return withCode(codeUnsupportedVersion, fmt.Errorf("unsupported webhook version %q, expected %q", n.Version, webhookVersion))
+	}
+
+	var problems []string
+	if n.Receiver == "" {
+		problems = append(problems, "receiver missing")
+	}
+	if n.Status != "firing" && n.Status != "resolved" {
+		problems = append(problems, fmt.Sprintf("status must be firing or resolved, not %q", n.Status))
+	}
+	if len(n.Alerts) == 0 {
+		problems = append(problems, "alerts missing")
+	}
+	for i, a := range n.Alerts {
+		if a.Status != "firing" && a.Status != "resolved" {
+			problems = append(problems, fmt.Sprintf("alerts[%d]: status must be firing or resolved, not %q", i, a.Status))
+		}
+	}
+	if len(problems) > 0 {
+		return withCode(codeInvalidPayload, fmt.Errorf("invalid webhook payload: %s", strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+// checkContentType fails requests whose body is not JSON. Requests without a
+// content type are assumed to be JSON.
+func checkContentType(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return withCode(codeUnsupportedMediaType, fmt.Errorf("unsupported content type %q, expected application/json", contentType))
+	}
+	return nil
+}
+
+// decodeError returns err, with which the body of a request failed to be read
+// or decoded, with its code.
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return withCode(codeRequestTooLarge, err)
+	}
+	return withCode(codeInvalidJSON, err)
+}
+
+// errorStatus returns the status of requests failing with err.
+func errorStatus(err error) int {
+	var re *requestError
+	if !errors.As(err, &re) {
+		return http.StatusBadRequest
+	}
+	switch re.code {
+	case codeUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case codeRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case codeUnknownInput:
+		return http.StatusNotFound
+	case codeUnknownProvider, codeTemplateError:
+		return http.StatusInternalServerError
+	case codeCircuitOpen, codeRateLimited:
+		return http.StatusServiceUnavailable
+	case codeSendFailed:
+		return http.StatusBadGateway
+	}
+	return http.StatusBadRequest
+}
+
+// limitRequestSize fails the requests to h whose body is larger than max
+// bytes, unless max is 0.
+func limitRequestSize(max int64, h http.Handler) http.Handler {
+	if max <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// notify sends the notification n, decoded from the webhook payload of r,
+// through its receiver, rendering the receiver's text against tmplData.
+func notify(w http.ResponseWriter, r *http.Request, n notification, tmplData interface{}) {
+	data := n.Data
+	ctx := r.Context()
+	l := logger(ctx).With("receiver", data.Receiver, "group_key", n.GroupKey)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		l = l.With("trace_id", sc.TraceID().String())
+	}
+	ctx = withLogger(ctx, l)
+	r = r.WithContext(ctx)
+
+	c := currentConfig()
+	_, span := tracer.Start(ctx, "route", trace.WithAttributes(attribute.String("sachet.receiver", data.Receiver)))
+	receiverConf := c.config.receiverConfByReceiver(data.Receiver)
+	if receiverConf == nil && c.config.DefaultReceiver != "" {
+		l.Warn("Unknown receiver, using the default receiver", "default_receiver", c.config.DefaultReceiver)
+		receiverConf = c.config.receiverConfByReceiver(c.config.DefaultReceiver)
+	}
 	if receiverConf == nil {
-		errorHandler(w, http.StatusBadRequest, fmt.Errorf("Receiver missing: %s", data.Receiver), "?")
+		err := withCode(codeUnknownReceiver, fmt.Errorf("Receiver missing: %s", data.Receiver))
+		endSpan(span, err)
+		errorHandler(w, r, errorStatus(err), err, "?")
 		return
 	}
-	provider, err := providerByName(receiverConf.Provider)
-	if err != nil {
-		errorHandler(w, http.StatusInternalServerError, err, receiverConf.Provider)
+	span.SetAttributes(attribute.String("sachet.provider", receiverConf.Provider))
+	provider, ok := c.providers[receiverConf.Provider]
+	if !ok {
+		err := withCode(codeUnknownProvider, fmt.Errorf("%s: Unknown provider", receiverConf.Provider))
+		endSpan(span, err)
+		errorHandler(w, r, errorStatus(err), err, receiverConf.Provider)
 		return
 	}
+	span.End()
 
-	var text string
-	if receiverConf.Text != "" {
-		text, err = tmpl.ExecuteTextString(receiverConf.Text, data)
-		if err != nil {
-			errorHandler(w, http.StatusInternalServerError, err, receiverConf.Provider)
-			return
-		}
-	} else {
-		text = newAlertText(data)
+	ctx = withLogger(ctx, l.With("provider", receiverConf.Provider))
+	r = r.WithContext(ctx)
+
+	if data.Status == "resolved" && !receiverConf.sendsResolved() {
+		logger(ctx).Info("Skipped resolved notification", "alerts", len(data.Alerts))
+		requestTotal.WithLabelValues("200", receiverConf.Provider).Inc()
+		return
 	}
 
-	message := sachet.Message{
-		To:   receiverConf.To,
-		From: receiverConf.From,
-		Type: receiverConf.Type,
-		Text: text,
+	notificationAlerts.WithLabelValues(receiverConf.Name).Observe(float64(len(data.Alerts)))
+
+	_, span = tracer.Start(ctx, "render", trace.WithAttributes(attribute.Int("sachet.alerts", len(data.Alerts))))
+	messages, err := c.renderMessages(receiverConf, provider, n, tmplData)
+	endSpan(span, err)
+	if err != nil {
+		templateErrorsTotal.WithLabelValues(receiverConf.Name).Inc()
+		errorHandler(w, r, http.StatusInternalServerError, withCode(codeTemplateError, err), receiverConf.Provider)
+		return
 	}
 
-	if err = provider.Send(message); err != nil {
-		errorHandler(w, http.StatusBadRequest, err, receiverConf.Provider)
+	// Every message is sent even if another one fails, and the notification
+	// fails with the first error.
+	var sendErr error
+	recipients := 0
+	for _, message := range messages {
+		results, err := sendMessage(ctx, c, receiverConf.Provider, provider, message)
+		observeMessage(receiverConf.Name, receiverConf.Provider, message, results, err)
+		if err != nil && sendErr == nil {
+			sendErr = err
+		}
+		recipients += len(message.To)
+	}
+	if sendErr != nil {
+		// Alertmanager retries notifications failing with 5xx, which
+		// rejected messages would fail with again.
+		code := codeSendFailed
+		switch {
+		case errors.Is(sendErr, errCircuitOpen):
+			code = codeCircuitOpen
+		case errors.Is(sendErr, errRateLimited):
+			code = codeRateLimited
+		case sachet.IsRejected(sendErr):
+			code = codeMessageRejected
+		}
+		err := withCode(code, sendErr)
+		errorHandler(w, r, errorStatus(err), err, receiverConf.Provider)
 		return
 	}
 
+	logger(ctx).Info("Sent notification", "status", data.Status, "alerts", len(data.Alerts), "recipients", recipients)
 	requestTotal.WithLabelValues("200", receiverConf.Provider).Inc()
 }
 
 func (h handlers) Reload(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	if r.Method == http.MethodPost {
-		log.Println("Loading configuration file", *configFile)
-		if err := LoadConfig(*configFile); err != nil {
+		if err := reloadConfig(*configFile, "reload request"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	} else {
 		http.Error(w, "Invalid request method.", http.StatusMethodNotAllowed)
 	}
 }
+
+// Config responds with the effective configuration as YAML, with secrets
+// redacted.
+func (h handlers) Config(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := configYAML()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	if _, err := w.Write(body); err != nil {
+		logger(r.Context()).Error("Error writing response", "err", err)
+	}
+}
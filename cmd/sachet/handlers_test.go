@@ -1,33 +1,59 @@
 package main
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
 )
 
 func Test_newAlertText(t *testing.T) {
 	t.Parallel()
 
+	startsAt := time.Date(2026, 10, 19, 8, 15, 0, 0, time.UTC)
+	alerts := template.Alerts{
+		{
+			Status:      "firing",
+			Labels:      template.KV{"alertname": "HighCPU", "instance": "node-1", "severity": "critical"},
+			Annotations: template.KV{"summary": "CPU at 97%", "description": "CPU has been above 90% for 10m"},
+			StartsAt:    startsAt,
+		},
+		{
+			Status:      "firing",
+			Labels:      template.KV{"alertname": "HighCPU", "instance": "node-2", "exported_instance": "vm-7"},
+			Annotations: template.KV{"description": "CPU has been above 90% for 10m"},
+		},
+		{
+			Status: "resolved",
+			Labels: template.KV{"alertname": "HighCPU", "instance": "node-3"},
+		},
+	}
+
 	cases := []struct {
-		name string
-		data template.Data
-		exp  string
+		name      string
+		conf      AlertTextConf
+		data      template.Data
+		truncated uint64
+		exp       string
 	}{
 		{
 			name: "empty",
 			data: template.Data{},
-			exp:  "Alert \n",
+			exp:  "ALERT\n",
 		},
 		{
 			name: "empty alerts",
-			data: template.Data{
-				Alerts: template.Alerts{
-					template.Alert{},
-				},
-			},
-			exp: " \n",
+			data: template.Data{Alerts: template.Alerts{template.Alert{}}},
+			exp:  "FIRING:1\n",
 		},
 		{
 			name: "alert labels",
@@ -42,21 +68,269 @@ func Test_newAlertText(t *testing.T) {
 					},
 				},
 			},
-			exp: " \nalertname= a\nexported_instance= a\ninstance= a",
+			exp: "FIRING:1\na a a",
 		},
 		{
 			name: "common labels",
-			data: template.Data{
-				CommonLabels: template.KV{
-					"a": "a",
-					"b": "b",
-					"c": "c",
-				},
+			data: template.Data{CommonLabels: template.KV{"a": "a", "b": "b", "c": "c"}},
+			exp:  "ALERT\na b c",
+		},
+		{
+			name: "common labels with status",
+			data: template.Data{Status: "firing", CommonLabels: template.KV{"a": "a", "b": "b"}},
+			exp:  "FIRING\na b",
+		},
+		{
+			name: "alert without labels or annotations",
+			data: template.Data{Alerts: template.Alerts{{Status: "firing", Labels: template.KV{"job": "node", "env": "prod"}}}},
+			exp:  "FIRING:1\nenv=prod job=node",
+		},
+		{
+			name: "defaults",
+			data: template.Data{Alerts: alerts},
+			exp:  "FIRING:2\nHighCPU node-1: CPU at 97%\nHighCPU node-2 vm-7: CPU has been above 90% for 10m\nRESOLVED:1\nHighCPU node-3",
+		},
+		{
+			name: "max alerts",
+			conf: AlertTextConf{MaxAlerts: 1},
+			data: template.Data{Alerts: alerts},
+			exp:  "FIRING:2\nHighCPU node-1: CPU at 97%\n+1 more\nRESOLVED:1\n+1 more",
+		},
+		{
+			name:      "truncated alerts",
+			data:      template.Data{Alerts: alerts[:1]},
+			truncated: 37,
+			exp:       "FIRING:1\nHighCPU node-1: CPU at 97%\n+37 more",
+		},
+		{
+			name:      "truncated resolved alerts",
+			conf:      AlertTextConf{MaxAlerts: 2},
+			data:      template.Data{Alerts: alerts},
+			truncated: 3,
+			exp:       "FIRING:2\nHighCPU node-1: CPU at 97%\nHighCPU node-2 vm-7: CPU has been above 90% for 10m\nRESOLVED:1\n+4 more",
+		},
+		{
+			name: "layout",
+			conf: AlertTextConf{
+				Labels:      []string{"instance"},
+				Annotations: []string{"description"},
+				Separator:   " | ",
+				Severity:    true,
+				StartTime:   true,
+				TimeFormat:  "15:04",
 			},
-			exp: "Alert \na | b | c",
+			data: template.Data{Alerts: alerts[:1]},
+			exp:  "FIRING:1\n[critical] node-1: CPU has been above 90% for 10m (since 08:15)",
 		},
 	}
 	for _, tc := range cases {
-		assert.Equal(t, tc.exp, newAlertText(tc.data), tc.name)
+		assert.Equal(t, tc.exp, newAlertText(tc.conf, notification{Data: tc.data, TruncatedAlerts: tc.truncated}, nil), tc.name)
+	}
+}
+
+type fakeProvider struct {
+	err error
+}
+
+func (p fakeProvider) Send(sachet.Message) error { return p.err }
+
+func Test_Alert_metrics(t *testing.T) {
+	c, _ := newTestConfig(t,
+		ReceiverConf{Name: "metrics-ok", Provider: "working", To: []string{"1", "2"}, Text: "{{ .Status }}"},
+		ReceiverConf{Name: "metrics-failing", Provider: "broken", To: []string{"1"}},
+		ReceiverConf{Name: "metrics-template", Provider: "working", To: []string{"1"}, Text: "{{ template \"missing\" . }}"},
+	)
+	c.providers["working"] = fakeProvider{}
+	c.providers["broken"] = fakeProvider{err: errors.New("gateway timeout")}
+
+	for _, receiver := range []string{"metrics-ok", "metrics-failing", "metrics-template"} {
+		body := `{"receiver": "` + receiver + `", "status": "firing", "alerts": [{"status": "firing"}, {"status": "firing"}]}`
+		w := httptest.NewRecorder()
+		handlers{}.Alert(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
 	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(messagesTotal.WithLabelValues("metrics-ok", "working", "success")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(recipientsTotal.WithLabelValues("metrics-ok", "working", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(messagesTotal.WithLabelValues("metrics-failing", "broken", "failure")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(templateErrorsTotal.WithLabelValues("metrics-template")))
+	assert.Equal(t, 1, testutil.CollectAndCount(providerSendDuration.WithLabelValues("broken", "failure").(prometheus.Histogram)))
+}
+
+func Test_Alert_sendFailed(t *testing.T) {
+	c, _ := newTestConfig(t,
+		ReceiverConf{Name: "failing", Provider: "broken", To: []string{"1"}},
+		ReceiverConf{Name: "rejecting", Provider: "rejecting", To: []string{"1"}},
+	)
+	c.providers["broken"] = fakeProvider{err: errors.New("gateway timeout")}
+	c.providers["rejecting"] = fakeProvider{err: sachet.Rejected(errors.New("invalid number"))}
+
+	// Failed sends are retried by Alertmanager, rejected messages are not.
+	for receiver, exp := range map[string]string{
+		"failing":   `{"Error":true,"Status":502,"Code":"send_failed","Message":"gateway timeout"}`,
+		"rejecting": `{"Error":true,"Status":400,"Code":"message_rejected","Message":"invalid number"}`,
+	} {
+		body := `{"receiver": "` + receiver + `", "status": "firing", "alerts": [{"status": "firing"}]}`
+		w := httptest.NewRecorder()
+		handlers{}.Alert(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
+		assert.JSONEq(t, exp, w.Body.String(), receiver)
+	}
+}
+
+func Test_Alert_status(t *testing.T) {
+	sendResolved := false
+	_, sent := newTestConfig(t,
+		ReceiverConf{
+			Name:         "chat",
+			Provider:     "recording",
+			To:           []string{"1"},
+			Text:         "{{ .Status }}",
+			TextResolved: "Resolved: {{ .CommonLabels.alertname }}",
+			Subject:      "[{{ .Status | toUpper }}] {{ .CommonLabels.alertname }}",
+		},
+		ReceiverConf{
+			Name:         "oncall",
+			Provider:     "recording",
+			To:           []string{"2"},
+			TextFiring:   "{{ .CommonLabels.alertname }} firing",
+			SendResolved: &sendResolved,
+		},
+	)
+
+	cases := []struct {
+		receiver string
+		status   string
+		exp      []sachet.Message
+	}{
+		{
+			receiver: "chat",
+			status:   "firing",
+			exp:      []sachet.Message{{To: []string{"1"}, Text: "firing", Subject: "[FIRING] HighCPU"}},
+		},
+		{
+			receiver: "chat",
+			status:   "resolved",
+			exp:      []sachet.Message{{To: []string{"1"}, Text: "Resolved: HighCPU", Subject: "[RESOLVED] HighCPU"}},
+		},
+		{
+			receiver: "oncall",
+			status:   "firing",
+			exp:      []sachet.Message{{To: []string{"2"}, Text: "HighCPU firing"}},
+		},
+		{
+			receiver: "oncall",
+			status:   "resolved",
+		},
+	}
+	for _, tc := range cases {
+		*sent = nil
+		body := `{"receiver": "` + tc.receiver + `", "status": "` + tc.status + `", "alerts": [{"status": "` + tc.status + `"}], "commonLabels": {"alertname": "HighCPU"}}`
+		w := httptest.NewRecorder()
+		handlers{}.Alert(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, w.Code, tc.receiver+" "+tc.status)
+		assert.Equal(t, tc.exp, *sent, tc.receiver+" "+tc.status)
+	}
+}
+
+func Test_Alert_truncatedAlerts(t *testing.T) {
+	_, sent := newTestConfig(t,
+		ReceiverConf{Name: "sms", Provider: "recording", To: []string{"1"}, Text: "v{{ .Version }} {{ len .Alerts }} alerts{{ with .TruncatedAlerts }} and {{ . }} more{{ end }}"},
+	)
+
+	body := `{"version": "4", "groupKey": "{}:{}", "truncatedAlerts": 37, "receiver": "sms", "status": "firing", "alerts": [{"status": "firing"}, {"status": "firing"}]}`
+	h := limitRequestSize(int64(len(body)), http.HandlerFunc(handlers{}.Alert))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []sachet.Message{{To: []string{"1"}, Text: "v4 2 alerts and 37 more"}}, *sent)
+
+	*sent = nil
+	h = limitRequestSize(int64(len(body)-1), http.HandlerFunc(handlers{}.Alert))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(t, *sent)
+}
+
+func Test_Alert_validation(t *testing.T) {
+	c, sent := newTestConfig(t, ReceiverConf{Name: "ops", Provider: "recording", To: []string{"1"}, Text: "{{ .Receiver }}"})
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		exp         string
+	}{
+		{
+			name:        "valid",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"version": "4", "receiver": "ops", "status": "firing", "alerts": [{"status": "firing"}]}`,
+			status:      http.StatusOK,
+		},
+		{
+			name:        "content type",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `{"receiver": "ops", "status": "firing", "alerts": [{"status": "firing"}]}`,
+			status:      http.StatusUnsupportedMediaType,
+			exp:         `{"Error":true,"Status":415,"Code":"unsupported_media_type","Message":"unsupported content type \"application/x-www-form-urlencoded\", expected application/json"}`,
+		},
+		{
+			name:   "malformed",
+			body:   `{"receiver": "ops",`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"invalid_json","Message":"unexpected EOF"}`,
+		},
+		{
+			name:   "version",
+			body:   `{"version": "5", "receiver": "ops", "status": "firing", "alerts": [{"status": "firing"}]}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"unsupported_version","Message":"unsupported webhook version \"5\", expected \"4\""}`,
+		},
+		{
+			name:   "required fields",
+			body:   `{"version": "4", "alerts": [{"status": "firing"}, {}]}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"invalid_payload","Message":"invalid webhook payload: receiver missing; status must be firing or resolved, not \"\"; alerts[1]: status must be firing or resolved, not \"\""}`,
+		},
+		{
+			name:   "unknown receiver",
+			body:   `{"receiver": "dev", "status": "firing", "alerts": [{"status": "firing"}]}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"unknown_receiver","Message":"Receiver missing: dev"}`,
+		},
+	}
+	for _, tc := range cases {
+		*sent = nil
+		req := httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		w := httptest.NewRecorder()
+		handlers{}.Alert(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		if tc.status == http.StatusOK {
+			assert.Len(t, *sent, 1, tc.name)
+			continue
+		}
+		assert.Equal(t, tc.exp, w.Body.String(), tc.name)
+		assert.Empty(t, *sent, tc.name)
+	}
+
+	// The default receiver gets the notifications of unknown receivers,
+	// which templates still see.
+	c.config.DefaultReceiver = "ops"
+	w := httptest.NewRecorder()
+	handlers{}.Alert(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(`{"receiver": "dev", "status": "firing", "alerts": [{"status": "firing"}]}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []sachet.Message{{To: []string{"1"}, Text: "dev"}}, *sent)
+}
+
+func Test_validateDefaultReceiver(t *testing.T) {
+	t.Parallel()
+
+	c := Config{Receivers: []ReceiverConf{{Name: "ops"}}, DefaultReceiver: "ops"}
+	assert.NoError(t, c.validateDefaultReceiver())
+	c.DefaultReceiver = "dev"
+	assert.EqualError(t, c.validateDefaultReceiver(), `default_receiver: receiver "dev" missing`)
 }
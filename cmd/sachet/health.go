@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/heptiolabs/healthcheck"
+
+	"github.com/messagebird/sachet"
+)
+
+// defaultHealthCheckInterval is used when health_checks.interval is not set.
+const defaultHealthCheckInterval = 5 * time.Minute
+
+// defaultHealthCheckTimeout is used when health_checks.timeout is not set.
+const defaultHealthCheckTimeout = 30 * time.Second
+
+// HealthCheckConf configures the background health checks of the providers
+// that support them.
+type HealthCheckConf struct {
+	// Interval between two checks of every provider.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout after which a check that has not completed counts as failed.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Readiness lists the providers whose failing health check makes
+	// /-/ready fail. Other providers are only reported in sachet_provider_up.
+	Readiness []string `yaml:"readiness,omitempty"`
+}
+
+// validate checks that the providers listed in Readiness exist.
+func (c *HealthCheckConf) validate() error {
+	known := map[string]bool{}
+	for _, name := range providerNames() {
+		known[name] = true
+	}
+	for _, name := range c.Readiness {
+		if !known[name] {
+			return fmt.Errorf("health_checks: readiness: unknown provider %q", name)
+		}
+	}
+	return nil
+}
+
+// errNotChecked is the readiness of a provider before its first health check.
+var errNotChecked = errors.New("not checked yet")
+
+// providerHealth holds the outcome of the last health check of every provider
+// by name. Providers missing from it were not checked yet.
+var providerHealth = struct {
+	sync.Mutex
+	results map[string]error
+}{results: map[string]error{}}
+
+// healthCheckNow makes the health checker check the providers right away,
+// for example after a reload replaced them.
+var healthCheckNow = make(chan struct{}, 1)
+
+// requestHealthCheck asks the health checker to run as soon as possible.
+func requestHealthCheck() {
+	select {
+	case healthCheckNow <- struct{}{}:
+	default:
+	}
+}
+
+// runHealthChecks checks the providers in use periodically, and registers
+// a readiness check with hc for every provider listed in health_checks.readiness.
+func runHealthChecks(hc healthcheck.Handler) {
+	registered := map[string]bool{}
+	for {
+		c := currentConfig()
+		for _, name := range c.config.HealthChecks.Readiness {
+			if !registered[name] {
+				name := name
+				hc.AddReadinessCheck("provider_"+name, func() error { return providerReadiness(name) })
+				registered[name] = true
+			}
+		}
+
+		timeout := c.config.HealthChecks.Timeout
+		if timeout <= 0 {
+			timeout = defaultHealthCheckTimeout
+		}
+		checkProviders(c.providers, timeout)
+
+		interval := c.config.HealthChecks.Interval
+		if interval <= 0 {
+			interval = defaultHealthCheckInterval
+		}
+		select {
+		case <-time.After(interval):
+		case <-healthCheckNow:
+		}
+	}
+}
+
+// checkProviders runs the health check of every provider that has one, at the
+// same time so that a hanging gateway does not delay the others. Checks taking
+// longer than timeout fail, so that a hanging gateway does not delay the next
+// round either.
+func checkProviders(providers map[string]sachet.Provider, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for name, provider := range providers {
+		checker, ok := provider.(sachet.HealthChecker)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, checker sachet.HealthChecker) {
+			defer wg.Done()
+
+			// A check that times out is left running in the background
+			// until the client of the provider gives up, and its outcome
+			// is dropped.
+			done := make(chan error, 1)
+			go func() { done <- checker.HealthCheck() }()
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(timeout):
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+			if err != nil {
+				slog.Warn("Health check failed", "provider", name, "err", err)
+				providerUp.WithLabelValues(name).Set(0)
+			} else {
+				providerUp.WithLabelValues(name).Set(1)
+			}
+
+			providerHealth.Lock()
+			providerHealth.results[name] = err
+			providerHealth.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+}
+
+// providerReadiness returns the outcome of the last health check of the
+// provider with that name, if its failures affect readiness.
+func providerReadiness(name string) error {
+	c := currentConfig()
+	affects := false
+	for _, n := range c.config.HealthChecks.Readiness {
+		affects = affects || n == name
+	}
+	if _, ok := c.providers[name].(sachet.HealthChecker); !ok || !affects {
+		return nil
+	}
+
+	providerHealth.Lock()
+	defer providerHealth.Unlock()
+	err, ok := providerHealth.results[name]
+	if !ok {
+		return errNotChecked
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+type checkedProvider struct {
+	err error
+	// hang, if set, makes the check wait until it is closed.
+	hang chan struct{}
+}
+
+func (p checkedProvider) Send(sachet.Message) error { return nil }
+func (p checkedProvider) HealthCheck() error {
+	if p.hang != nil {
+		<-p.hang
+	}
+	return p.err
+}
+
+func Test_providerReadiness(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	c := &loadedConfig{providers: map[string]sachet.Provider{
+		"telegram": checkedProvider{err: errors.New("Unauthorized")},
+		"twilio":   checkedProvider{},
+		"kannel":   checkedProvider{err: errors.New("connection refused")},
+		"otc":      checkedProvider{hang: hang},
+	}}
+	c.config.HealthChecks.Readiness = []string{"telegram", "twilio", "otc"}
+	useConfig(t, c)
+
+	assert.Equal(t, errNotChecked, providerReadiness("telegram"))
+
+	checkProviders(c.providers, 50*time.Millisecond)
+
+	assert.EqualError(t, providerReadiness("telegram"), "Unauthorized")
+	assert.NoError(t, providerReadiness("twilio"))
+	assert.NoError(t, providerReadiness("kannel"), "not listed in readiness")
+	assert.EqualError(t, providerReadiness("otc"), "timed out after 50ms")
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(providerUp.WithLabelValues("telegram")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(providerUp.WithLabelValues("twilio")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(providerUp.WithLabelValues("kannel")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(providerUp.WithLabelValues("otc")))
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+
+	"github.com/messagebird/sachet/jsonpath"
+)
+
+// inputAdapter converts the webhook payloads of a monitoring system other
+// than Alertmanager into Alertmanager notifications.
+type inputAdapter interface {
+	// convert returns the notification payload amounts to.
+	convert(payload []byte) (template.Data, error)
+}
+
+// builtinInputs are the adapters served at /input/<name> without being
+// configured. Grafana has a handler of its own, as its templates get more
+// than template.Data.
+var builtinInputs = map[string]inputAdapter{
+	"zabbix":      zabbixInput{},
+	"uptime-kuma": uptimeKumaInput{},
+}
+
+// JSONInputConf maps the JSON payloads posted to /input/<name> to alerts.
+// Paths are JSONPath expressions such as $.host.name.
+type JSONInputConf struct {
+	Name string
+
+	// Receiver is the receiver of every notification, unless ReceiverPath
+	// selects one in the payload.
+	Receiver     string `yaml:",omitempty"`
+	ReceiverPath string `yaml:"receiver_path,omitempty"`
+
+	// AlertsPath selects the alerts in the payload. By default the payload
+	// is a single alert. The other paths are relative to each alert.
+	AlertsPath string `yaml:"alerts_path,omitempty"`
+	// StatusPath selects the status of an alert.
+	StatusPath string `yaml:"status_path,omitempty"`
+	// ResolvedStatuses are the statuses of resolved alerts. Alerts with any
+	// other status, or without one, are firing.
+	ResolvedStatuses []string `yaml:"resolved_statuses,omitempty"`
+
+	// Labels and Annotations map names to the paths of their values.
+	Labels      map[string]string `yaml:",omitempty"`
+	Annotations map[string]string `yaml:",omitempty"`
+}
+
+// inputName matches the names inputs can be served at.
+var inputName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// loadInputs returns the built-in inputs and those configured in
+// JSONInputs, by name.
+func (c *Config) loadInputs() (map[string]inputAdapter, error) {
+	inputs := map[string]inputAdapter{}
+	for name, adapter := range builtinInputs {
+		inputs[name] = adapter
+	}
+
+	for i, conf := range c.JSONInputs {
+		if !inputName.MatchString(conf.Name) {
+			return nil, fmt.Errorf("json_inputs[%d]: name %q must be made of lower case letters, digits, - and _", i, conf.Name)
+		}
+		if _, ok := inputs[conf.Name]; ok || conf.Name == "grafana" {
+			return nil, fmt.Errorf("json_inputs[%d]: name %q is already taken", i, conf.Name)
+		}
+		adapter, err := newJSONInput(conf)
+		if err != nil {
+			return nil, fmt.Errorf("json_inputs[%d]: %w", i, err)
+		}
+		inputs[conf.Name] = adapter
+	}
+	return inputs, nil
+}
+
+// Input sends the notifications posted to /input/<name> by other monitoring
+// systems through their receiver, or the one named by the receiver query
+// parameter.
+func (h handlers) Input(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	name := strings.TrimPrefix(r.URL.Path, "/input/")
+	adapter, ok := currentConfig().inputs[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, withCode(codeUnknownInput, fmt.Errorf("unknown input %q", name)))
+		return
+	}
+
+	_, span := tracer.Start(r.Context(), "decode")
+	payload, err := io.ReadAll(r.Body)
+	var data template.Data
+	if err != nil {
+		err = decodeError(err)
+	} else if data, err = adapter.convert(payload); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			err = withCode(codeInvalidJSON, err)
+		} else {
+			err = withCode(codeInvalidPayload, err)
+		}
+	}
+	endSpan(span, err)
+	if err != nil {
+		errorHandler(w, r, errorStatus(err), err, "?")
+		return
+	}
+
+	if receiver := r.URL.Query().Get("receiver"); receiver != "" {
+		data.Receiver = receiver
+	}
+	n := notification{Data: data}
+	notify(w, r, n, n)
+}
+
+// newNotification returns the notification of alerts to receiver. It is
+// firing if any of the alerts is.
+func newNotification(receiver string, alerts template.Alerts) template.Data {
+	data := template.Data{
+		Receiver:          receiver,
+		Status:            "resolved",
+		Alerts:            alerts,
+		GroupLabels:       template.KV{},
+		CommonLabels:      template.KV{},
+		CommonAnnotations: template.KV{},
+	}
+	if len(alerts.Firing()) > 0 {
+		data.Status = "firing"
+	}
+	if len(alerts) == 0 {
+		return data
+	}
+
+	for k, v := range alerts[0].Labels {
+		data.CommonLabels[k] = v
+	}
+	for k, v := range alerts[0].Annotations {
+		data.CommonAnnotations[k] = v
+	}
+	for _, a := range alerts[1:] {
+		for k, v := range data.CommonLabels {
+			if a.Labels[k] != v {
+				delete(data.CommonLabels, k)
+			}
+		}
+		for k, v := range data.CommonAnnotations {
+			if a.Annotations[k] != v {
+				delete(data.CommonAnnotations, k)
+			}
+		}
+	}
+	return data
+}
+
+// setNonEmpty sets kv[k] to v unless v is empty.
+func setNonEmpty(kv template.KV, k, v string) {
+	if v != "" {
+		kv[k] = v
+	}
+}
+
+// zabbixInput converts the payloads of the Zabbix webhook media type
+// described in the README, whose parameters are Zabbix macros.
+type zabbixInput struct{}
+
+type zabbixEvent struct {
+	Receiver   string `json:"receiver"`
+	EventID    string `json:"event_id"`
+	EventValue string `json:"event_value"`
+	EventName  string `json:"event_name"`
+	Severity   string `json:"event_severity"`
+	Host       string `json:"host"`
+	TriggerID  string `json:"trigger_id"`
+	Subject    string `json:"subject"`
+	Message    string `json:"message"`
+}
+
+func (zabbixInput) convert(payload []byte) (template.Data, error) {
+	var e zabbixEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return template.Data{}, err
+	}
+
+	a := template.Alert{
+		Status:      "firing",
+		Labels:      template.KV{},
+		Annotations: template.KV{},
+		Fingerprint: e.EventID,
+	}
+	// {EVENT.VALUE} is 1 for problems and 0 for recoveries.
+	if e.EventValue == "0" {
+		a.Status = "resolved"
+	}
+	setNonEmpty(a.Labels, "alertname", e.EventName)
+	setNonEmpty(a.Labels, "instance", e.Host)
+	setNonEmpty(a.Labels, "severity", e.Severity)
+	setNonEmpty(a.Labels, "event_id", e.EventID)
+	setNonEmpty(a.Labels, "trigger_id", e.TriggerID)
+	setNonEmpty(a.Annotations, "summary", e.Subject)
+	setNonEmpty(a.Annotations, "description", e.Message)
+
+	return newNotification(e.Receiver, template.Alerts{a}), nil
+}
+
+// uptimeKumaInput converts the payloads of the Uptime Kuma webhook
+// notification type. They name no receiver, so it is given with the receiver
+// query parameter.
+type uptimeKumaInput struct{}
+
+type uptimeKumaPayload struct {
+	Heartbeat *struct {
+		// Status is 0 when down, 1 when up, 2 when pending and 3 during
+		// maintenance.
+		Status int    `json:"status"`
+		Time   string `json:"time"`
+		Msg    string `json:"msg"`
+	} `json:"heartbeat"`
+	Monitor *struct {
+		Name     string `json:"name"`
+		URL      string `json:"url"`
+		Hostname string `json:"hostname"`
+		Type     string `json:"type"`
+	} `json:"monitor"`
+	Msg string `json:"msg"`
+}
+
+// uptimeKumaTime is the layout of heartbeat times, which are in UTC.
+const uptimeKumaTime = "2006-01-02 15:04:05.000"
+
+func (uptimeKumaInput) convert(payload []byte) (template.Data, error) {
+	var p uptimeKumaPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return template.Data{}, err
+	}
+	if p.Msg == "" && p.Heartbeat == nil {
+		return template.Data{}, errors.New("msg and heartbeat missing")
+	}
+
+	a := template.Alert{
+		Status:      "firing",
+		Labels:      template.KV{},
+		Annotations: template.KV{},
+	}
+	setNonEmpty(a.Annotations, "summary", p.Msg)
+
+	// Test notifications have neither monitor nor heartbeat.
+	if p.Monitor != nil {
+		setNonEmpty(a.Labels, "alertname", p.Monitor.Name)
+		instance := p.Monitor.URL
+		if instance == "" || instance == "https://" {
+			instance = p.Monitor.Hostname
+		}
+		setNonEmpty(a.Labels, "instance", instance)
+		setNonEmpty(a.Labels, "monitor_type", p.Monitor.Type)
+	}
+	if p.Heartbeat != nil {
+		setNonEmpty(a.Annotations, "description", p.Heartbeat.Msg)
+		t, err := time.Parse(uptimeKumaTime, p.Heartbeat.Time)
+		switch p.Heartbeat.Status {
+		case 1, 3:
+			a.Status = "resolved"
+			if err == nil {
+				a.EndsAt = t
+			}
+		default:
+			if err == nil {
+				a.StartsAt = t
+			}
+		}
+	}
+
+	return newNotification("", template.Alerts{a}), nil
+}
+
+// jsonInput converts JSON payloads as configured by a JSONInputConf.
+type jsonInput struct {
+	receiver         string
+	receiverPath     *jsonpath.Path
+	alertsPath       *jsonpath.Path
+	statusPath       *jsonpath.Path
+	resolvedStatuses map[string]bool
+	labels           map[string]*jsonpath.Path
+	annotations      map[string]*jsonpath.Path
+}
+
+// newJSONInput compiles the paths of conf.
+func newJSONInput(conf JSONInputConf) (*jsonInput, error) {
+	in := &jsonInput{
+		receiver:         conf.Receiver,
+		resolvedStatuses: map[string]bool{},
+		labels:           map[string]*jsonpath.Path{},
+		annotations:      map[string]*jsonpath.Path{},
+	}
+
+	compile := func(key, expr string) (*jsonpath.Path, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		p, err := jsonpath.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return p, nil
+	}
+
+	var err error
+	if in.receiverPath, err = compile("receiver_path", conf.ReceiverPath); err != nil {
+		return nil, err
+	}
+	if in.alertsPath, err = compile("alerts_path", conf.AlertsPath); err != nil {
+		return nil, err
+	}
+	if in.statusPath, err = compile("status_path", conf.StatusPath); err != nil {
+		return nil, err
+	}
+	for _, status := range conf.ResolvedStatuses {
+		in.resolvedStatuses[status] = true
+	}
+	for _, name := range sortedKeys(conf.Labels) {
+		if in.labels[name], err = compile("labels."+name, conf.Labels[name]); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(conf.Annotations) {
+		if in.annotations[name], err = compile("annotations."+name, conf.Annotations[name]); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (in *jsonInput) convert(payload []byte) (template.Data, error) {
+	var doc interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return template.Data{}, err
+	}
+
+	receiver := in.receiver
+	if in.receiverPath != nil {
+		if r, ok := in.receiverPath.FindString(doc); ok && r != "" {
+			receiver = r
+		}
+	}
+
+	items := []interface{}{doc}
+	if in.alertsPath != nil {
+		// Sending a message without alerts would hide a payload that changed
+		// format or a mistyped path.
+		if items = in.alertsPath.Find(doc); len(items) == 0 {
+			return template.Data{}, fmt.Errorf("alerts_path %s matched no alerts", in.alertsPath)
+		}
+	}
+
+	alerts := make(template.Alerts, 0, len(items))
+	for _, item := range items {
+		a := template.Alert{
+			Status:      "firing",
+			Labels:      template.KV{},
+			Annotations: template.KV{},
+		}
+		if in.statusPath != nil {
+			if status, ok := in.statusPath.FindString(item); ok && in.resolvedStatuses[status] {
+				a.Status = "resolved"
+			}
+		}
+		for name, p := range in.labels {
+			v, _ := p.FindString(item)
+			setNonEmpty(a.Labels, name, v)
+		}
+		for name, p := range in.annotations {
+			v, _ := p.FindString(item)
+			setNonEmpty(a.Annotations, name, v)
+		}
+		alerts = append(alerts, a)
+	}
+
+	return newNotification(receiver, alerts), nil
+}
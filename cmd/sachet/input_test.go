@@ -0,0 +1,227 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+func Test_inputAdapters(t *testing.T) {
+	t.Parallel()
+
+	icinga, err := newJSONInput(JSONInputConf{
+		Name:             "icinga",
+		Receiver:         "ops",
+		ReceiverPath:     "$.contact",
+		AlertsPath:       "$.checks[*]",
+		StatusPath:       "$.state",
+		ResolvedStatuses: []string{"OK", "UP"},
+		Labels:           map[string]string{"alertname": "$.service", "instance": "$.host"},
+		Annotations:      map[string]string{"summary": "$.output"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		adapter inputAdapter
+		payload string
+		exp     template.Data
+		err     string
+	}{
+		{
+			name:    "zabbix problem",
+			adapter: zabbixInput{},
+			payload: `{"receiver": "team-sms", "event_id": "42", "event_value": "1", "event_name": "High CPU", "event_severity": "High", "host": "db-1", "trigger_id": "7", "subject": "Problem: High CPU", "message": "CPU at 97%", "url": "http://sachet/input/zabbix"}`,
+			exp: template.Data{
+				Receiver: "team-sms",
+				Status:   "firing",
+				Alerts: template.Alerts{{
+					Status:      "firing",
+					Labels:      template.KV{"alertname": "High CPU", "instance": "db-1", "severity": "High", "event_id": "42", "trigger_id": "7"},
+					Annotations: template.KV{"summary": "Problem: High CPU", "description": "CPU at 97%"},
+					Fingerprint: "42",
+				}},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{"alertname": "High CPU", "instance": "db-1", "severity": "High", "event_id": "42", "trigger_id": "7"},
+				CommonAnnotations: template.KV{"summary": "Problem: High CPU", "description": "CPU at 97%"},
+			},
+		},
+		{
+			name:    "zabbix recovery",
+			adapter: zabbixInput{},
+			payload: `{"receiver": "team-sms", "event_value": "0", "event_name": "High CPU"}`,
+			exp: template.Data{
+				Receiver:          "team-sms",
+				Status:            "resolved",
+				Alerts:            template.Alerts{{Status: "resolved", Labels: template.KV{"alertname": "High CPU"}, Annotations: template.KV{}}},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{"alertname": "High CPU"},
+				CommonAnnotations: template.KV{},
+			},
+		},
+		{
+			name:    "uptime kuma down",
+			adapter: uptimeKumaInput{},
+			payload: `{"heartbeat": {"monitorID": 3, "status": 0, "time": "2026-10-19 08:15:02.123", "msg": "timeout of 48000ms exceeded"}, "monitor": {"id": 3, "name": "Website", "url": "https://example.com", "hostname": null, "type": "http"}, "msg": "[Website] [Down] timeout of 48000ms exceeded"}`,
+			exp: template.Data{
+				Status: "firing",
+				Alerts: template.Alerts{{
+					Status:      "firing",
+					Labels:      template.KV{"alertname": "Website", "instance": "https://example.com", "monitor_type": "http"},
+					Annotations: template.KV{"summary": "[Website] [Down] timeout of 48000ms exceeded", "description": "timeout of 48000ms exceeded"},
+					StartsAt:    time.Date(2026, 10, 19, 8, 15, 2, 123000000, time.UTC),
+				}},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{"alertname": "Website", "instance": "https://example.com", "monitor_type": "http"},
+				CommonAnnotations: template.KV{"summary": "[Website] [Down] timeout of 48000ms exceeded", "description": "timeout of 48000ms exceeded"},
+			},
+		},
+		{
+			name:    "uptime kuma test notification",
+			adapter: uptimeKumaInput{},
+			payload: `{"heartbeat": null, "monitor": null, "msg": "Sachet Testing"}`,
+			exp: template.Data{
+				Status:            "firing",
+				Alerts:            template.Alerts{{Status: "firing", Labels: template.KV{}, Annotations: template.KV{"summary": "Sachet Testing"}}},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{},
+				CommonAnnotations: template.KV{"summary": "Sachet Testing"},
+			},
+		},
+		{
+			name:    "uptime kuma empty",
+			adapter: uptimeKumaInput{},
+			payload: `{}`,
+			err:     "msg and heartbeat missing",
+		},
+		{
+			name:    "json mapping",
+			adapter: icinga,
+			payload: `{"checks": [{"host": "web-1", "service": "http", "state": "CRITICAL", "output": "connection refused"}, {"host": "web-2", "service": "http", "state": "OK"}]}`,
+			exp: template.Data{
+				Receiver: "ops",
+				Status:   "firing",
+				Alerts: template.Alerts{
+					{Status: "firing", Labels: template.KV{"alertname": "http", "instance": "web-1"}, Annotations: template.KV{"summary": "connection refused"}},
+					{Status: "resolved", Labels: template.KV{"alertname": "http", "instance": "web-2"}, Annotations: template.KV{}},
+				},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{"alertname": "http"},
+				CommonAnnotations: template.KV{},
+			},
+		},
+		{
+			name:    "json mapping receiver path",
+			adapter: icinga,
+			payload: `{"contact": "dba", "checks": [{"host": "db-1", "service": "mysql", "state": "OK"}]}`,
+			exp: template.Data{
+				Receiver:          "dba",
+				Status:            "resolved",
+				Alerts:            template.Alerts{{Status: "resolved", Labels: template.KV{"alertname": "mysql", "instance": "db-1"}, Annotations: template.KV{}}},
+				GroupLabels:       template.KV{},
+				CommonLabels:      template.KV{"alertname": "mysql", "instance": "db-1"},
+				CommonAnnotations: template.KV{},
+			},
+		},
+		{
+			name:    "json mapping without alerts",
+			adapter: icinga,
+			payload: `{"contact": "dba", "checks": []}`,
+			err:     "alerts_path $.checks[*] matched no alerts",
+		},
+		{
+			name:    "invalid json",
+			adapter: icinga,
+			payload: `{"checks":`,
+			err:     "unexpected end of JSON input",
+		},
+	}
+	for _, tc := range cases {
+		data, err := tc.adapter.convert([]byte(tc.payload))
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.exp, data, tc.name)
+	}
+}
+
+func Test_loadInputs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		inputs []JSONInputConf
+		err    string
+	}{
+		{
+			name:   "valid",
+			inputs: []JSONInputConf{{Name: "icinga", Labels: map[string]string{"instance": "$.host"}}},
+		},
+		{
+			name:   "built-in name",
+			inputs: []JSONInputConf{{Name: "grafana"}},
+			err:    `json_inputs[0]: name "grafana" is already taken`,
+		},
+		{
+			name:   "duplicate name",
+			inputs: []JSONInputConf{{Name: "icinga"}, {Name: "icinga"}},
+			err:    `json_inputs[1]: name "icinga" is already taken`,
+		},
+		{
+			name:   "invalid name",
+			inputs: []JSONInputConf{{Name: "icinga/2"}},
+			err:    `json_inputs[0]: name "icinga/2" must be made of lower case letters, digits, - and _`,
+		},
+		{
+			name:   "invalid path",
+			inputs: []JSONInputConf{{Name: "icinga", Labels: map[string]string{"instance": "host"}}},
+			err:    `json_inputs[0]: labels.instance: jsonpath "host": must start with $`,
+		},
+	}
+	for _, tc := range cases {
+		c := Config{JSONInputs: tc.inputs}
+		_, err := c.loadInputs()
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+		} else {
+			assert.NoError(t, err, tc.name)
+		}
+	}
+}
+
+func Test_Input(t *testing.T) {
+	c, sent := newTestConfig(t, ReceiverConf{Name: "team-sms", Provider: "recording", To: []string{"1"}, Text: "{{ .Status }}: {{ .CommonLabels.alertname }}"})
+	c.config.JSONInputs = []JSONInputConf{{Name: "icinga", Receiver: "team-sms", AlertsPath: "$.checks[*]"}}
+	var err error
+	if c.inputs, err = c.config.loadInputs(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := `{"heartbeat": {"status": 1, "time": "2026-10-19 08:20:00.000", "msg": "200 - OK"}, "monitor": {"name": "Website", "url": "https://example.com"}, "msg": "[Website] [Up] 200 - OK"}`
+	w := httptest.NewRecorder()
+	handlers{}.Input(w, httptest.NewRequest(http.MethodPost, "/input/uptime-kuma?receiver=team-sms", strings.NewReader(payload)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []sachet.Message{{To: []string{"1"}, Text: "resolved: Website"}}, *sent)
+
+	w = httptest.NewRecorder()
+	handlers{}.Input(w, httptest.NewRequest(http.MethodPost, "/input/nagios", strings.NewReader(payload)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Payloads without alerts are rejected rather than sent as empty messages.
+	w = httptest.NewRecorder()
+	handlers{}.Input(w, httptest.NewRequest(http.MethodPost, "/input/icinga", strings.NewReader(`{"checks": []}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), codeInvalidPayload)
+	assert.Len(t, *sent, 1)
+}
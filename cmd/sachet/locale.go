@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ContactConf is a recipient of a receiver with a locale or time zone of its
+// own, instead of those of the receiver.
+type ContactConf struct {
+	To       string
+	Locale   string `yaml:",omitempty"`
+	TimeZone string `yaml:"time_zone,omitempty"`
+}
+
+// builtinTranslations translate the text of receivers without a text
+// template, by locale. time_format is the layout of times.
+var builtinTranslations = map[string]map[string]string{
+	"fa": {
+		"FIRING":      "فعال",
+		"RESOLVED":    "برطرف شد",
+		"+%d more":    "+%d مورد دیگر",
+		"since %s":    "از %s",
+		"time_format": "2006/01/02 15:04 MST",
+	},
+	"fr": {
+		"FIRING":      "ALERTE",
+		"RESOLVED":    "RÉSOLU",
+		"+%d more":    "+%d autres",
+		"since %s":    "depuis %s",
+		"time_format": "02/01/2006 15:04 MST",
+	},
+	"uk": {
+		"FIRING":      "ТРИВОГА",
+		"RESOLVED":    "ВИРІШЕНО",
+		"+%d more":    "ще %d",
+		"since %s":    "з %s",
+		"time_format": "02.01.2006 15:04 MST",
+	},
+}
+
+// formatVerbs are the verbs of the translated format strings. Their
+// translations are formatted with a single value, so they must have that
+// verb exactly once.
+var formatVerbs = map[string]byte{
+	"+%d more": 'd',
+	"since %s": 's',
+}
+
+// formatVerb matches the verbs of format strings, the verb being captured.
+var formatVerb = regexp.MustCompile(`%[-+# 0]*(?:\[[0-9]+\])?[0-9]*(?:\.[0-9]*)?([a-zA-Z%])`)
+
+// validateFormat checks that translation, the translation of key, has the
+// verb of key if key is a format string.
+func validateFormat(key, translation string) error {
+	want, ok := formatVerbs[key]
+	if !ok {
+		return nil
+	}
+	var verbs []string
+	for _, m := range formatVerb.FindAllStringSubmatch(translation, -1) {
+		if m[1] != "%" {
+			verbs = append(verbs, m[1])
+		}
+	}
+	if len(verbs) != 1 || verbs[0] != string(want) {
+		return fmt.Errorf("%q must contain %%%c exactly once, and no other verb", key, want)
+	}
+	return nil
+}
+
+// defaultTimeFormat is the layout of times without a locale.
+const defaultTimeFormat = "Jan 2 15:04 MST"
+
+// localeName matches BCP 47 language tags such as fr or pt-BR.
+var localeName = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// locale is the language and time zone messages are rendered in. A nil
+// locale renders them in English and UTC.
+type locale struct {
+	name      string
+	location  *time.Location
+	catalogue map[string]string
+}
+
+// newLocale returns the locale with that name and time zone, translated with
+// the built-in translations and those of the configuration, which take
+// precedence. Translations of the language of a regional locale, fr for
+// fr-CA, apply to it too.
+func (c *Config) newLocale(name, timeZone string) (*locale, error) {
+	if name != "" && !localeName.MatchString(name) {
+		return nil, fmt.Errorf("invalid locale %q", name)
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", timeZone)
+	}
+
+	l := &locale{name: name, location: location, catalogue: map[string]string{}}
+	for _, catalogue := range []map[string]map[string]string{builtinTranslations, c.Translations} {
+		for _, tag := range l.tags() {
+			for k, v := range catalogue[tag] {
+				l.catalogue[k] = v
+			}
+		}
+	}
+	return l, nil
+}
+
+// tags returns the language of l and then l itself, the most specific last.
+func (l *locale) tags() []string {
+	if l == nil || l.name == "" {
+		return nil
+	}
+	if i := strings.IndexByte(l.name, '-'); i > 0 {
+		return []string{l.name[:i], l.name}
+	}
+	return []string{l.name}
+}
+
+// translate returns the translation of s, or s if there is none.
+func (l *locale) translate(s string) string {
+	if l != nil {
+		if t, ok := l.catalogue[s]; ok {
+			return t
+		}
+	}
+	return s
+}
+
+// formatTime formats t in the time zone of l with layout, by default the
+// time_format of l.
+func (l *locale) formatTime(t time.Time, layout string) string {
+	if layout == "" {
+		layout = l.translate("time_format")
+		if layout == "time_format" {
+			layout = defaultTimeFormat
+		}
+	}
+	location := time.UTC
+	if l != nil {
+		location = l.location
+	}
+	return t.In(location).Format(layout)
+}
+
+// funcs returns the template functions bound to l.
+func (l *locale) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"tr": l.translate,
+		"formatTime": func(t time.Time, layout ...string) string {
+			return l.formatTime(t, strings.Join(layout, ""))
+		},
+	}
+}
+
+// audience is the recipients of a receiver who get their messages in the
+// same locale.
+type audience struct {
+	to       []string
+	locale   string
+	timeZone string
+}
+
+// audiences groups the recipients of rc and its contacts by locale and time
+// zone, in the order of their first recipient.
+func (rc *ReceiverConf) audiences() []audience {
+	var audiences []audience
+	add := func(to, locale, timeZone string) {
+		if locale == "" {
+			locale = rc.Locale
+		}
+		if timeZone == "" {
+			timeZone = rc.TimeZone
+		}
+		for i := range audiences {
+			if audiences[i].locale == locale && audiences[i].timeZone == timeZone {
+				audiences[i].to = append(audiences[i].to, to)
+				return
+			}
+		}
+		audiences = append(audiences, audience{to: []string{to}, locale: locale, timeZone: timeZone})
+	}
+	for _, to := range rc.To {
+		add(to, "", "")
+	}
+	for _, contact := range rc.Contacts {
+		add(contact.To, contact.Locale, contact.TimeZone)
+	}
+	if len(audiences) == 0 {
+		audiences = []audience{{locale: rc.Locale, timeZone: rc.TimeZone}}
+	}
+	return audiences
+}
+
+// recipients returns the recipients of rc and its contacts.
+func (rc *ReceiverConf) recipients() []string {
+	to := append([]string(nil), rc.To...)
+	for _, contact := range rc.Contacts {
+		to = append(to, contact.To)
+	}
+	return to
+}
+
+// validateLocales checks the translations, and the locales and time zones of
+// the receivers and their contacts.
+func (c *Config) validateLocales() error {
+	for name, catalogue := range c.Translations {
+		if !localeName.MatchString(name) {
+			return fmt.Errorf("translations: invalid locale %q", name)
+		}
+		for key, translation := range catalogue {
+			if err := validateFormat(key, translation); err != nil {
+				return fmt.Errorf("translations.%s: %w", name, err)
+			}
+		}
+	}
+	for _, rc := range c.Receivers {
+		for _, a := range rc.audiences() {
+			if _, err := c.newLocale(a.locale, a.timeZone); err != nil {
+				return fmt.Errorf("receiver %q: %w", rc.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+func Test_newLocale(t *testing.T) {
+	t.Parallel()
+
+	c := Config{Translations: map[string]map[string]string{
+		"fr":    {"Firing": "En cours"},
+		"fr-CA": {"Firing": "En cours au Québec"},
+	}}
+
+	l, err := c.newLocale("fr-CA", "America/Montreal")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "En cours au Québec", l.translate("Firing"))
+		assert.Equal(t, "RÉSOLU", l.translate("RESOLVED"))
+		assert.Equal(t, "Missing", l.translate("Missing"))
+		assert.Equal(t, "19/10/2026 04:15 EDT", l.formatTime(time.Date(2026, 10, 19, 8, 15, 0, 0, time.UTC), ""))
+	}
+
+	var nilLocale *locale
+	assert.Equal(t, "Firing", nilLocale.translate("Firing"))
+	assert.Equal(t, "Oct 19 08:15 UTC", nilLocale.formatTime(time.Date(2026, 10, 19, 8, 15, 0, 0, time.UTC), ""))
+
+	_, err = c.newLocale("French", "")
+	assert.EqualError(t, err, `invalid locale "French"`)
+	_, err = c.newLocale("fr", "Europe/Lyon")
+	assert.EqualError(t, err, `invalid time zone "Europe/Lyon"`)
+}
+
+func Test_audiences(t *testing.T) {
+	t.Parallel()
+
+	rc := ReceiverConf{
+		To:       []string{"+33600000001", "+33600000002"},
+		Locale:   "fr",
+		TimeZone: "Europe/Paris",
+		Contacts: []ContactConf{
+			{To: "+989120000001", Locale: "fa", TimeZone: "Asia/Tehran"},
+			{To: "+33600000003"},
+			{To: "+380500000001", Locale: "uk", TimeZone: "Europe/Kyiv"},
+			{To: "+989120000002", Locale: "fa", TimeZone: "Asia/Tehran"},
+		},
+	}
+	assert.Equal(t, []audience{
+		{to: []string{"+33600000001", "+33600000002", "+33600000003"}, locale: "fr", timeZone: "Europe/Paris"},
+		{to: []string{"+989120000001", "+989120000002"}, locale: "fa", timeZone: "Asia/Tehran"},
+		{to: []string{"+380500000001"}, locale: "uk", timeZone: "Europe/Kyiv"},
+	}, rc.audiences())
+	assert.Equal(t, []string{"+33600000001", "+33600000002", "+989120000001", "+33600000003", "+380500000001", "+989120000002"}, rc.recipients())
+
+	assert.Equal(t, []audience{{locale: "fr"}}, (&ReceiverConf{Locale: "fr"}).audiences())
+}
+
+func Test_validateLocales(t *testing.T) {
+	t.Parallel()
+
+	c := Config{Receivers: []ReceiverConf{{Name: "oncall", Contacts: []ContactConf{{To: "1", TimeZone: "Mars/Olympus"}}}}}
+	assert.EqualError(t, c.validateLocales(), `receiver "oncall": invalid time zone "Mars/Olympus"`)
+
+	c = Config{Translations: map[string]map[string]string{"French": {}}}
+	assert.EqualError(t, c.validateLocales(), `translations: invalid locale "French"`)
+
+	for translation, valid := range map[string]bool{
+		"+%d autres":     true,
+		"%d%% de plus":   true,
+		"+%[1]d autres":  true,
+		"autres":         false,
+		"+%s autres":     false,
+		"+%d autres %d":  false,
+		"+%d autres %s!": false,
+	} {
+		c = Config{Translations: map[string]map[string]string{"fr": {"+%d more": translation}}}
+		if valid {
+			assert.NoError(t, c.validateLocales(), translation)
+		} else {
+			assert.EqualError(t, c.validateLocales(), `translations.fr: "+%d more" must contain %d exactly once, and no other verb`, translation)
+		}
+	}
+
+	// The built-in translations are formatted the same way.
+	for name, catalogue := range builtinTranslations {
+		for key, translation := range catalogue {
+			assert.NoError(t, validateFormat(key, translation), name)
+		}
+	}
+}
+
+func Test_Alert_locales(t *testing.T) {
+	c, sent := newTestConfig(t,
+		ReceiverConf{
+			Name:     "templated",
+			Provider: "recording",
+			To:       []string{"1"},
+			Contacts: []ContactConf{{To: "2", Locale: "fr", TimeZone: "Europe/Paris"}},
+			Text:     `{{ define "title.fr" }}Alerte{{ end }}{{ define "title" }}Alert{{ end }}{{ template "title" . }}: {{ .CommonLabels.instance }} {{ tr "down" }}{{ range .Alerts }} {{ formatTime .StartsAt "15:04" }}{{ end }}`,
+		},
+		ReceiverConf{
+			Name:     "default",
+			Provider: "recording",
+			To:       []string{"1"},
+			Locale:   "uk",
+			TimeZone: "Europe/Kyiv",
+			AlertText: &AlertTextConf{
+				StartTime: true,
+			},
+		},
+	)
+	c.config.Translations = map[string]map[string]string{"fr": {"down": "en panne"}}
+
+	cases := []struct {
+		receiver string
+		exp      []sachet.Message
+	}{
+		{
+			receiver: "templated",
+			exp: []sachet.Message{
+				{To: []string{"1"}, Text: "Alert: node-1 down 08:15"},
+				{To: []string{"2"}, Text: "Alerte: node-1 en panne 10:15"},
+			},
+		},
+		{
+			receiver: "default",
+			exp: []sachet.Message{
+				{To: []string{"1"}, Text: "ТРИВОГА:1\nHighCPU node-1 (з 19.10.2026 11:15 EEST)"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		*sent = nil
+		body := `{"receiver": "` + tc.receiver + `", "status": "firing", "alerts": [{"status": "firing", "labels": {"alertname": "HighCPU", "instance": "node-1"}, "startsAt": "2026-10-19T08:15:00Z"}], "commonLabels": {"instance": "node-1"}}`
+		w := httptest.NewRecorder()
+		handlers{}.Alert(w, httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, w.Code, tc.receiver)
+		assert.Equal(t, tc.exp, *sent, tc.receiver)
+	}
+}
+
+func Test_templates_execute(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := newTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := (&Config{}).newLocale("fr-CA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := `{{ define "a" }}a{{ end }}{{ define "a.fr" }}a.fr{{ end }}{{ define "b" }}b{{ end }}{{ define "b.fr" }}b.fr{{ end }}{{ define "b.fr-CA" }}b.fr-CA{{ end }}{{ template "a" . }} {{ template "b" . }} {{ tr "FIRING" }}`
+
+	out, err := tmpl.execute(text, template.Data{}, l)
+	assert.NoError(t, err)
+	assert.Equal(t, "a.fr b.fr-CA ALERTE", out)
+
+	out, err = tmpl.ExecuteTextString(text, template.Data{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a b FIRING", out)
+}
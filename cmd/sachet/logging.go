@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxLoggedBody is how much of the bodies of outbound requests and their
+// responses is logged at debug level.
+const maxLoggedBody = 4096
+
+// newLogger returns a logger writing records from level up to w, formatted
+// as "logfmt" or "json".
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "logfmt":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, must be logfmt or json", format)
+}
+
+// fatal logs msg with err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a copy of ctx carrying l.
+func withLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// logger returns the logger of the request ctx belongs to, or the default
+// logger outside of requests.
+func logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// withRequestID gives every request an ID, taken from its X-Request-Id header
+// if the caller set one, and returns it in the X-Request-Id response header.
+// Everything logged for the request carries the ID.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", id)
+
+		l := slog.Default().With("request_id", id)
+		h.ServeHTTP(w, r.WithContext(withLogger(r.Context(), l)))
+	})
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// newSecretReplacer returns a replacer of secrets, as they are and URL
+// encoded, with a placeholder. Longer secrets are replaced first, so that a
+// secret containing another one is redacted as a whole.
+func newSecretReplacer(secrets []string) *strings.Replacer {
+	var forms []string
+	for _, s := range secrets {
+		forms = append(forms, s, url.QueryEscape(s), url.PathEscape(s))
+	}
+	sort.Slice(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+
+	var oldnew []string
+	for i, s := range forms {
+		if i > 0 && s == forms[i-1] {
+			continue
+		}
+		oldnew = append(oldnew, s, "<secret>")
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// loggingTransport logs the requests providers make, and their responses, at
+// debug level, with the provider credentials redacted.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	l := logger(ctx)
+	if !l.Enabled(ctx, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+
+	c := currentConfig()
+	attrs := []any{"method", req.Method, "url", c.redact(req.URL.Redacted())}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			attrs = append(attrs, "request_body", c.redact(readLogged(body)))
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs = append(attrs, "duration", time.Since(start))
+	if err != nil {
+		l.Debug("Provider request failed", append(attrs, "err", c.redact(err.Error()))...)
+		return resp, err
+	}
+
+	// Read the start of the body for the log and hand the provider all of it.
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	l.Debug("Provider request", append(attrs, "status", resp.StatusCode, "response_body", c.redact(string(head)))...)
+	return resp, nil
+}
+
+// readLogged returns the start of r to be logged.
+func readLogged(r io.Reader) string {
+	b, _ := io.ReadAll(io.LimitReader(r, maxLoggedBody))
+	return string(b)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newLogger(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		level  string
+		format string
+		err    string
+	}{
+		{level: "debug", format: "logfmt"},
+		{level: "warn", format: "json"},
+		{level: "verbose", format: "logfmt", err: `invalid log level "verbose"`},
+		{level: "info", format: "text", err: `invalid log format "text", must be logfmt or json`},
+	}
+	for _, tc := range cases {
+		_, err := newLogger(ioutil.Discard, tc.level, tc.format)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.level+" "+tc.format)
+		} else {
+			assert.NoError(t, err, tc.level+" "+tc.format)
+		}
+	}
+}
+
+func Test_Alert_logging(t *testing.T) {
+	var out bytes.Buffer
+	l, err := newLogger(&out, "info", "logfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(l)
+
+	c, _ := newTestConfig(t, ReceiverConf{Name: "team-sms", Provider: "working", To: []string{"1"}})
+	c.providers["working"] = fakeProvider{}
+
+	body := `{"receiver": "team-sms", "groupKey": "{}:{alertname=\"Down\"}", "status": "firing", "alerts": [{"status": "firing"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body))
+	r.Header.Set("X-Request-Id", "req-1")
+	w := httptest.NewRecorder()
+	withRequestID(http.HandlerFunc(handlers{}.Alert)).ServeHTTP(w, r)
+
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-Id"))
+	assert.Contains(t, out.String(), `msg="Sent notification" request_id=req-1 receiver=team-sms group_key="{}:{alertname=\"Down\"}" provider=working status=firing alerts=1 recipients=1`)
+}
+
+func Test_loggingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error": "invalid key s3cr3t/+"}`))
+	}))
+	defer server.Close()
+
+	useConfig(t, &loadedConfig{secrets: newSecretReplacer([]string{"s3cr3t/+"})})
+
+	var out bytes.Buffer
+	l, err := newLogger(&out, "debug", "logfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withLogger(context.Background(), l.With("request_id", "req-2"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/send?key=s3cr3t%2F%2B", strings.NewReader("key=s3cr3t/+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: loggingTransport{http.DefaultTransport}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, `{"error": "invalid key s3cr3t/+"}`, string(respBody))
+	assert.NotContains(t, out.String(), "s3cr3t")
+	assert.Contains(t, out.String(), `level=DEBUG msg="Provider request" request_id=req-2 method=POST url="`+server.URL+`/send?key=<secret>" request_body="key=<secret>"`)
+	assert.Contains(t, out.String(), `status=200 response_body="{\"error\": \"invalid key <secret>\"}"`)
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
+	// Embeds the time zone database, which the container image lacks, for
+	// the time zones of receivers.
+	_ "time/tzdata"
 
 	"github.com/heptiolabs/healthcheck"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	"github.com/messagebird/sachet"
 	"github.com/messagebird/sachet/provider/aliyun"
@@ -20,6 +30,7 @@ import (
 	"github.com/messagebird/sachet/provider/esendex"
 	"github.com/messagebird/sachet/provider/exotel"
 	"github.com/messagebird/sachet/provider/freemobile"
+	"github.com/messagebird/sachet/provider/generichttp"
 	"github.com/messagebird/sachet/provider/ghasedak"
 	"github.com/messagebird/sachet/provider/infobip"
 	"github.com/messagebird/sachet/provider/kannel"
@@ -46,42 +57,192 @@ import (
 )
 
 var (
-	listenAddress = flag.String("listen-address", ":9876", "The address to listen on for HTTP requests.")
-	configFile    = flag.String("config", "config.yaml", "The configuration file")
+	listenAddress      = flag.String("listen-address", ":9876", "The address to listen on for HTTP requests.")
+	configFile         = flag.String("config", "config.yaml", "The configuration file")
+	webConfigFile      = flag.String("web-config-file", "", "Path to the web configuration file enabling TLS and authentication.")
+	shutdownDelay      = flag.Duration("shutdown-delay", 0, "How long to keep serving with /-/ready failing after SIGTERM, so that load balancers stop routing to this instance.")
+	shutdownTimeout    = flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for requests in flight to complete when shutting down.")
+	tracingEndpoint    = flag.String("tracing-endpoint", "", "OTLP/HTTP endpoint to export traces to, such as http://otel-collector:4318. Tracing is disabled unless this or OTEL_EXPORTER_OTLP_ENDPOINT is set.")
+	tracingSampleRatio = flag.Float64("tracing-sample-ratio", 1, "Fraction of the alert requests to trace, unless Alertmanager sampled them already.")
+	logLevel           = flag.String("log-level", "info", "Only log messages with the given severity or above: debug, info, warn or error. At debug level, the requests made to providers are logged with their credentials redacted.")
+	logFormat          = flag.String("log-format", "logfmt", "Output format of the log messages: logfmt or json.")
+	maxRequestSize     = flag.Int64("max-request-size", 4<<20, "Maximum size in bytes of the bodies of the requests to /alert, /input/ and /api/v1/send, larger ones failing with 413. 0 disables the limit.")
+	watchInterval      = flag.Duration("config-watch-interval", 0, "Interval at which to check the configuration file and templates for changes and reload them. 0 disables watching.")
+
+	printConfig       = flag.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit.")
+	printConfigSchema = flag.Bool("print-config-schema", false, "Print the JSON Schema of the configuration file and exit.")
 )
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	l, err := newLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(l)
+
+	switch command := flag.Arg(0); command {
+	case "":
+	case "check-config":
+		filename := *configFile
+		if flag.NArg() > 1 {
+			filename = flag.Arg(1)
+		}
+		if !checkConfig(os.Stdout, filename) {
+			os.Exit(1)
+		}
+		return
+	case "send":
+		os.Exit(runSend(flag.Args()[1:]))
+	case "template":
+		os.Exit(runTemplate(flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	if *printConfigSchema {
+		b, err := configSchemaJSON()
+		if err != nil {
+			fatal("Error generating configuration schema", err)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+
+	debug, tracing := l.Enabled(context.Background(), slog.LevelDebug), tracingEnabled(*tracingEndpoint)
+	wrapProviderTransport = func(t http.RoundTripper) http.RoundTripper {
+		if debug {
+			t = loggingTransport{t}
+		}
+		if tracing {
+			t = otelhttp.NewTransport(t)
+		}
+		return t
+	}
 
 	if err := LoadConfig(*configFile); err != nil {
-		log.Fatalf("Error loading configuration: %s", err)
+		fatal("Error loading configuration", err)
+	}
+
+	if *printConfig {
+		b, err := configYAML()
+		if err != nil {
+			fatal("Error printing configuration", err)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+
+	web, err := loadWebConfig(*webConfigFile)
+	if err != nil {
+		fatal("Error loading web configuration", err)
+	}
+
+	shutdownTracing := func(context.Context) error { return nil }
+	if tracing {
+		if shutdownTracing, err = setupTracing(*tracingEndpoint, *tracingSampleRatio); err != nil {
+			fatal("Error setting up tracing", err)
+		}
+	}
+
+	go reloadOnSignal(*configFile)
+	if *watchInterval > 0 {
+		go watchConfig(*configFile, *watchInterval)
 	}
 
 	app := handlers{}
+	mux := http.NewServeMux()
 
-	http.HandleFunc("/alert", app.Alert)
-	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/-/reload", app.Reload)
+	mux.Handle("/alert", otelhttp.NewHandler(
+		web.protect("alert", limitRequestSize(*maxRequestSize, instrumentDuration("/alert", app.Alert))),
+		"/alert",
+	))
+	mux.Handle("/input/grafana", otelhttp.NewHandler(
+		web.protect("alert", limitRequestSize(*maxRequestSize, instrumentDuration("/input/grafana", app.Grafana))),
+		"/input/grafana",
+	))
+	mux.Handle("/input/", otelhttp.NewHandler(
+		web.protect("alert", limitRequestSize(*maxRequestSize, instrumentDuration("/input/", app.Input))),
+		"/input",
+	))
+	mux.Handle("/api/v1/send", otelhttp.NewHandler(
+		web.protect("alert", limitRequestSize(*maxRequestSize, instrumentDuration("/api/v1/send", app.Send))),
+		"/api/v1/send",
+	))
+	mux.Handle("/metrics", web.protect("metrics", promhttp.Handler()))
+	mux.Handle("/-/reload", web.protect("admin", http.HandlerFunc(app.Reload)))
+	mux.Handle("/api/v1/template/render", web.protect("admin", http.HandlerFunc(app.RenderTemplate)))
+	mux.Handle("/api/v1/config", web.protect("admin", http.HandlerFunc(app.Config)))
 
 	hc := healthcheck.NewMetricsHandler(prometheus.DefaultRegisterer, "sachet")
 
-	http.HandleFunc("/-/live", hc.LiveEndpoint)
-	http.HandleFunc("/-/ready", hc.ReadyEndpoint)
+	// Health checks stay open so that orchestrators can probe them.
+	mux.HandleFunc("/-/live", hc.LiveEndpoint)
+	mux.HandleFunc("/-/ready", hc.ReadyEndpoint)
+	hc.AddReadinessCheck("shutdown", shutdownCheck)
+	go runHealthChecks(hc)
 
 	if os.Getenv("PORT") != "" {
 		*listenAddress = ":" + os.Getenv("PORT")
 	}
 
-	log.Printf("Listening on %s", *listenAddress)
+	server := &http.Server{
+		Addr:    *listenAddress,
+		Handler: withRequestID(mux),
+	}
+
+	if web.TLSServerConfig != nil {
+		if server.TLSConfig, err = web.tlsConfig(); err != nil {
+			fatal("Error loading web configuration", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		fatal("Error listening", err)
+	}
+	slog.Info("Listening", "address", *listenAddress)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	if err := serve(server, listener, stop, *shutdownDelay, *shutdownTimeout); err != nil {
+		fatal("Error serving", err)
+	}
 
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "err", err)
+	}
+}
+
+// instrumentDuration measures the time h takes to handle requests, labelled
+// with the handler name.
+func instrumentDuration(handler string, h http.HandlerFunc) http.Handler {
+	return promhttp.InstrumentHandlerDuration(requestDuration.MustCurryWith(prometheus.Labels{"handler": handler}), h)
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage of %s:
+  %[1]s [flags]                       Run the HTTP server.
+  %[1]s [flags] check-config [file]   Check a configuration file and exit.
+  %[1]s [flags] send [send flags]     Send a single message and exit. See send -h.
+  %[1]s template render [flags]       Render a template and exit. See template render -h.
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
 }
 
 // receiverConfByReceiver loops the receiver conf list and returns the first instance with that name.
-func receiverConfByReceiver(name string) *ReceiverConf {
-	for i := range config.Receivers {
-		rc := &config.Receivers[i]
+func (c *Config) receiverConfByReceiver(name string) *ReceiverConf {
+	for i := range c.Receivers {
+		rc := &c.Receivers[i]
 		if rc.Name == name {
 			return rc
 		}
@@ -89,94 +250,149 @@ func receiverConfByReceiver(name string) *ReceiverConf {
 	return nil
 }
 
-func providerByName(name string) (sachet.Provider, error) {
-	// TODO: use map of providers instead
+// providerByName creates the provider with that name from its configuration.
+func (c *Config) providerByName(name string) (sachet.Provider, error) {
 	switch name {
 	case "messagebird":
-		return messagebird.NewMessageBird(config.Providers.MessageBird), nil
+		return messagebird.NewMessageBird(c.Providers.MessageBird), nil
 	case "nexmo":
-		return nexmo.NewNexmo(config.Providers.Nexmo)
+		return nexmo.NewNexmo(c.Providers.Nexmo)
 	case "twilio":
-		return twilio.NewTwilio(config.Providers.Twilio), nil
+		return twilio.NewTwilio(c.Providers.Twilio), nil
 	case "infobip":
-		return infobip.NewInfobip(config.Providers.Infobip), nil
+		return infobip.NewInfobip(c.Providers.Infobip), nil
 	case "kannel":
-		return kannel.NewKannel(config.Providers.Kannel), nil
+		return kannel.NewKannel(c.Providers.Kannel), nil
 	case "kavenegar":
-		return kavenegar.NewKaveNegar(config.Providers.KaveNegar), nil
+		return kavenegar.NewKaveNegar(c.Providers.KaveNegar), nil
 	case "turbosms":
-		return turbosms.NewTurbosms(config.Providers.Turbosms), nil
+		return turbosms.NewTurbosms(c.Providers.Turbosms), nil
 	case "smsc":
-		return smsc.NewSmsc(config.Providers.Smsc), nil
+		return smsc.NewSmsc(c.Providers.Smsc), nil
 	case "exotel":
-		return exotel.NewExotel(config.Providers.Exotel), nil
+		return exotel.NewExotel(c.Providers.Exotel), nil
 	case "cm":
-		return cm.NewCM(config.Providers.CM), nil
+		return cm.NewCM(c.Providers.CM), nil
 	case "telegram":
-		return telegram.NewTelegram(config.Providers.Telegram)
+		return telegram.NewTelegram(c.Providers.Telegram), nil
 	case "mailruim":
-		return mailruim.NewMailruIM(config.Providers.MailruIM)
+		return mailruim.NewMailruIM(c.Providers.MailruIM), nil
 	case "otc":
-		return otc.NewOTC(config.Providers.OTC), nil
+		return otc.NewOTC(c.Providers.OTC), nil
 	case "mediaburst":
-		return mediaburst.NewMediaBurst(config.Providers.MediaBurst), nil
+		return mediaburst.NewMediaBurst(c.Providers.MediaBurst), nil
 	case "freemobile":
-		return freemobile.NewFreeMobile(config.Providers.FreeMobile), nil
+		return freemobile.NewFreeMobile(c.Providers.FreeMobile), nil
 	case "aspsms":
-		return aspsms.NewAspSms(config.Providers.AspSms), nil
+		return aspsms.NewAspSms(c.Providers.AspSms), nil
 	case "sipgate":
-		return sipgate.NewSipgate(config.Providers.Sipgate), nil
+		return sipgate.NewSipgate(c.Providers.Sipgate), nil
 	case "pushbullet":
-		return pushbullet.NewPushbullet(config.Providers.Pushbullet), nil
+		return pushbullet.NewPushbullet(c.Providers.Pushbullet), nil
 	case "nowsms":
-		return nowsms.NewNowSms(config.Providers.NowSms), nil
+		return nowsms.NewNowSms(c.Providers.NowSms), nil
 	case "aliyun":
-		return aliyun.NewAliyun(config.Providers.Aliyun)
+		return aliyun.NewAliyun(c.Providers.Aliyun)
 	case "ovh":
-		return ovh.NewOvh(config.Providers.OVH)
+		return ovh.NewOvh(c.Providers.OVH)
 	case "tencentcloud":
-		return tencentcloud.NewTencentCloud(config.Providers.TencentCloud), nil
+		return tencentcloud.NewTencentCloud(c.Providers.TencentCloud), nil
 	case "sap":
-		return sap.NewSap(config.Providers.Sap), nil
+		return sap.NewSap(c.Providers.Sap), nil
 	case "esendex":
-		return esendex.NewEsendex(config.Providers.Esendex), nil
+		return esendex.NewEsendex(c.Providers.Esendex), nil
 	case "sms77":
-		return sms77.NewSms77(config.Providers.Sms77), nil
+		return sms77.NewSms77(c.Providers.Sms77), nil
 	case "ghasedak":
-		return ghasedak.NewGhasedak(config.Providers.Ghasedak), nil
+		return ghasedak.NewGhasedak(c.Providers.Ghasedak), nil
 	case "sfr":
-		return sfr.NewSfr(config.Providers.Sfr), nil
+		return sfr.NewSfr(c.Providers.Sfr), nil
 	case "textmagic":
-		return textmagic.NewTextMagic(config.Providers.TextMagic), nil
+		return textmagic.NewTextMagic(c.Providers.TextMagic), nil
 	case "melipayamak":
-		return melipayamak.NewMelipayamak(config.Providers.Melipayamak), nil
+		return melipayamak.NewMelipayamak(c.Providers.Melipayamak), nil
+	case "http":
+		p, err := generichttp.NewGenericHTTP(c.Providers.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("providers.http: %w", err)
+		}
+		return p, nil
 	}
 
 	return nil, fmt.Errorf("%s: Unknown provider", name)
 }
 
-func errorHandler(w http.ResponseWriter, status int, err error, provider string) {
-	w.WriteHeader(status)
+func errorHandler(w http.ResponseWriter, r *http.Request, status int, err error, provider string) {
+	writeError(w, r, status, err)
+	requestTotal.WithLabelValues(strconv.FormatInt(int64(status), 10), provider).Inc()
+}
+
+// Codes of the errors responded to notifications, for callers to tell them
+// apart without parsing messages.
+const (
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeRequestTooLarge      = "request_too_large"
+	codeInvalidJSON          = "invalid_json"
+	codeUnsupportedVersion   = "unsupported_version"
+	codeInvalidPayload       = "invalid_payload"
+	codeUnknownInput         = "unknown_input"
+	codeUnknownReceiver      = "unknown_receiver"
+	codeUnknownProvider      = "unknown_provider"
+	codeUnusedProvider       = "unused_provider"
+	codeTemplateError        = "template_error"
+	codeCircuitOpen          = "circuit_open"
+	codeRateLimited          = "rate_limited"
+	codeSendFailed           = "send_failed"
+	codeMessageRejected      = "message_rejected"
+)
+
+// requestError is an error with the code it is responded with.
+type requestError struct {
+	code string
+	err  error
+}
+
+// withCode returns err with the code it is responded with.
+func withCode(code string, err error) error {
+	return &requestError{code: code, err: err}
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
 
+// writeError responds to r with status and err as a JSON body, and logs it.
+// The body has the code of err, if it has one.
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	var code string
+	var re *requestError
+	if errors.As(err, &re) {
+		code = re.code
+	}
 	data := struct {
 		Error   bool
 		Status  int
+		Code    string `json:",omitempty"`
 		Message string
 	}{
 		true,
 		status,
+		code,
 		err.Error(),
 	}
-	// respond json
-	body, err := json.Marshal(data)
-	if err != nil {
-		log.Fatalf("marshalling error: " + err.Error())
-	}
+	l := logger(r.Context())
 
-	if _, err := w.Write(body); err != nil {
-		log.Fatalf("marshalling error: " + err.Error())
+	// respond json, or plain text if it cannot be marshalled
+	body, merr := json.Marshal(data)
+	if merr != nil {
+		l.Error("Error marshalling response", "err", merr)
+		http.Error(w, err.Error(), status)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if _, werr := w.Write(body); werr != nil {
+			l.Error("Error writing response", "err", werr)
+		}
 	}
 
-	log.Println("error: " + string(body))
-	requestTotal.WithLabelValues(strconv.FormatInt(int64(status), 10), provider).Inc()
+	l.Error("Request failed", "path", r.URL.Path, "status", status, "err", err)
 }
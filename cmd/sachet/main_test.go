@@ -7,8 +7,55 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
 )
 
+// recordingProvider records the messages sent through it.
+type recordingProvider struct {
+	sent *[]sachet.Message
+}
+
+func (p recordingProvider) Send(message sachet.Message) error {
+	*p.sent = append(*p.sent, message)
+	return nil
+}
+
+// newTestConfig serves a configuration with receivers until the test ends.
+// Its "recording" provider records the messages it sends, which are
+// returned. Circuit breakers are disabled, so that failures do not leak into
+// other tests.
+func newTestConfig(t *testing.T, receivers ...ReceiverConf) (*loadedConfig, *[]sachet.Message) {
+	t.Helper()
+
+	tmpl, err := newTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := &[]sachet.Message{}
+	c := &loadedConfig{
+		tmpl:      tmpl,
+		providers: map[string]sachet.Provider{"recording": recordingProvider{sent: sent}},
+	}
+	c.config.CircuitBreaker.Disabled = true
+	c.config.Receivers = receivers
+	useConfig(t, c)
+	return c, sent
+}
+
+// useConfig serves c until the test ends.
+func useConfig(t *testing.T, c *loadedConfig) {
+	restoreConfig(t)
+	current.Store(c)
+}
+
+// restoreConfig serves the configuration in use again when the test ends,
+// for tests that replace it with LoadConfig.
+func restoreConfig(t *testing.T) {
+	previous, _ := current.Load().(*loadedConfig)
+	t.Cleanup(func() { current.Store(previous) })
+}
+
 func Test_errorHandler(t *testing.T) {
 	t.Parallel()
 
@@ -17,6 +64,6 @@ func Test_errorHandler(t *testing.T) {
 		expect = `{"Error":true,"Status":403,"Message":"access forbidden"}`
 		err    = errors.New("access forbidden")
 	)
-	errorHandler(w, http.StatusForbidden, err, "test")
+	errorHandler(w, httptest.NewRequest(http.MethodPost, "/alert", nil), http.StatusForbidden, err, "test")
 	assert.Equal(t, expect, w.Body.String())
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RateLimitConf limits the messages sent through a provider, for gateways
+// that throttle or bill bursts.
+type RateLimitConf struct {
+	// Rate is the number of messages sent per second.
+	Rate float64 `yaml:"rate"`
+	// Burst is the number of messages that can be sent at once before Rate
+	// applies. Defaults to Rate rounded up.
+	Burst int `yaml:"burst,omitempty"`
+}
+
+func (c RateLimitConf) burst() float64 {
+	if c.Burst > 0 {
+		return float64(c.Burst)
+	}
+	return math.Max(1, math.Ceil(c.Rate))
+}
+
+// validateRateLimits checks that the rate limits are set for known providers
+// and are positive.
+func (c *Config) validateRateLimits() error {
+	known := map[string]bool{}
+	for _, name := range providerNames() {
+		known[name] = true
+	}
+	names := make([]string, 0, len(c.RateLimits))
+	for name := range c.RateLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conf := c.RateLimits[name]
+		switch {
+		case !known[name]:
+			return fmt.Errorf("rate_limits: unknown provider %q", name)
+		case conf.Rate <= 0:
+			return fmt.Errorf("rate_limits.%s: rate must be positive", name)
+		case conf.Burst < 0:
+			return fmt.Errorf("rate_limits.%s: burst must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// errRateLimited is returned for sends rejected by the rate limit of their
+// provider.
+var errRateLimited = errors.New("rate limit of the provider exceeded, not sending")
+
+// rateLimiter is a token bucket holding the messages a provider may send.
+// Limiters are kept by provider name across reloads.
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+var rateLimiters = struct {
+	sync.Mutex
+	byName map[string]*rateLimiter
+}{byName: map[string]*rateLimiter{}}
+
+// rateLimiterFor returns the rate limiter of the provider with that name.
+func rateLimiterFor(name string) *rateLimiter {
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+
+	l, ok := rateLimiters.byName[name]
+	if !ok {
+		l = &rateLimiter{tokens: math.Inf(1), now: time.Now}
+		rateLimiters.byName[name] = l
+	}
+	return l
+}
+
+// allow takes a message from the bucket, and returns errRateLimited if it
+// is empty.
+func (l *rateLimiter) allow(conf RateLimitConf) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * conf.Rate
+	}
+	l.tokens = math.Min(l.tokens, conf.burst())
+	l.last = now
+
+	if l.tokens < 1 {
+		return errRateLimited
+	}
+	l.tokens--
+	return nil
+}
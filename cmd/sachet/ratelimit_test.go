@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := &rateLimiter{tokens: 2, now: func() time.Time { return now }}
+	conf := RateLimitConf{Rate: 0.5, Burst: 2}
+
+	// A burst goes through, then the bucket is empty.
+	assert.NoError(t, l.allow(conf))
+	assert.NoError(t, l.allow(conf))
+	assert.ErrorIs(t, l.allow(conf), errRateLimited)
+
+	// It refills at the rate, up to the burst.
+	now = now.Add(2 * time.Second)
+	assert.NoError(t, l.allow(conf))
+	assert.ErrorIs(t, l.allow(conf), errRateLimited)
+	now = now.Add(time.Hour)
+	assert.NoError(t, l.allow(conf))
+	assert.NoError(t, l.allow(conf))
+	assert.ErrorIs(t, l.allow(conf), errRateLimited)
+}
+
+func Test_validateRateLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		limits map[string]RateLimitConf
+		err    string
+	}{
+		{map[string]RateLimitConf{"twilio": {Rate: 1, Burst: 5}}, ""},
+		{map[string]RateLimitConf{"twillio": {Rate: 1}}, `rate_limits: unknown provider "twillio"`},
+		{map[string]RateLimitConf{"twilio": {}}, "rate_limits.twilio: rate must be positive"},
+		{map[string]RateLimitConf{"twilio": {Rate: 1, Burst: -1}}, "rate_limits.twilio: burst must not be negative"},
+	}
+	for _, tc := range cases {
+		c := Config{RateLimits: tc.limits}
+		if tc.err == "" {
+			assert.NoError(t, c.validateRateLimits())
+			continue
+		}
+		assert.EqualError(t, c.validateRateLimits(), tc.err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/messagebird/sachet"
+)
+
+// reloadConfig reloads filename, logging why and whether it failed.
+func reloadConfig(filename, trigger string) error {
+	slog.Info("Loading configuration", "file", filename, "trigger", trigger)
+	err := LoadConfig(filename)
+	if err != nil {
+		slog.Error("Error reloading configuration, keeping the previous one", "err", err)
+	}
+	return err
+}
+
+// reloadOnSignal reloads filename every time the process receives SIGHUP.
+func reloadOnSignal(filename string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		_ = reloadConfig(filename, "SIGHUP")
+	}
+}
+
+// watchConfig reloads filename every time it or one of the template or secret
+// files it refers to changes, checking every interval. Files are compared by
+// content, so this also works for Kubernetes ConfigMaps, which are updated by
+// swapping symbolic links.
+func watchConfig(filename string, interval time.Duration) {
+	last := configChecksum(filename)
+	for range time.Tick(interval) {
+		sum := configChecksum(filename)
+		if sum == last {
+			continue
+		}
+		last = sum
+		_ = reloadConfig(filename, "file changed")
+	}
+}
+
+// configChecksum returns a checksum of filename and of the files the
+// configuration in use refers to. Files that cannot be read are left out,
+// which changes the checksum, so the reload that follows reports them.
+func configChecksum(filename string) [sha256.Size]byte {
+	files := append([]string{filename}, currentConfig().files()...)
+
+	h := sha256.New()
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		h.Write([]byte(file))
+		h.Write(content)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// files returns the template files, of every scope, and the secret files c was
+// loaded from, besides the configuration file itself.
+func (c *loadedConfig) files() []string {
+	globs := append([]string(nil), c.config.Templates...)
+	// Sorted, as the order of the files changes the checksum.
+	providers := make([]string, 0, len(c.config.ProviderTemplates))
+	for provider := range c.config.ProviderTemplates {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		globs = append(globs, c.config.ProviderTemplates[provider]...)
+	}
+	for _, rc := range c.config.Receivers {
+		globs = append(globs, rc.Templates...)
+	}
+
+	var files []string
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			continue
+		}
+		files = append(files, matches...)
+	}
+	return append(files, sachet.SecretFiles(&c.config.Providers)...)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadConfig_keepsPreviousOnError(t *testing.T) {
+	restoreConfig(t)
+
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+receivers:
+  - name: 'team-sms'
+    provider: 'twilio'
+    to: ['+31612345678']
+`)
+	assert.NoError(t, LoadConfig(filename))
+	assert.Equal(t, 1.0, testutil.ToFloat64(configLastReloadSuccessful))
+	before := currentConfig()
+	sum := configChecksum(filename)
+
+	// The first receiver is valid, the second one is not: nothing of the new
+	// file may be used.
+	write(`
+receivers:
+  - name: 'team-sms'
+    provider: 'messagebird'
+    to: ['+31612345678']
+  - name: 'team-chat'
+    provider: 'telegram'
+    to: ['@me']
+`)
+	assert.NotEqual(t, sum, configChecksum(filename))
+	assert.Error(t, LoadConfig(filename))
+	assert.Equal(t, 0.0, testutil.ToFloat64(configLastReloadSuccessful))
+	assert.Same(t, before, currentConfig())
+	assert.Equal(t, "twilio", currentConfig().config.receiverConfByReceiver("team-sms").Provider)
+}
+
+func Test_configChecksum(t *testing.T) {
+	dir := t.TempDir()
+	write := func(filename, content string) {
+		if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, "config.yaml")
+	tokenFile := filepath.Join(dir, "token")
+	templateFile := filepath.Join(dir, "sms.tmpl")
+	providerFile := filepath.Join(dir, "telegram.tmpl")
+	receiverFile := filepath.Join(dir, "ops.tmpl")
+	write(tokenFile, "123:abc")
+	write(templateFile, `{{ define "sms" }}{{ .Status }}{{ end }}`)
+	write(providerFile, `{{ define "chat" }}{{ .Status }}{{ end }}`)
+	write(receiverFile, `{{ define "ops" }}{{ .Status }}{{ end }}`)
+	write(filename, fmt.Sprintf(`
+templates: ['%s']
+provider_templates:
+  telegram: ['%s']
+providers:
+  telegram:
+    token_file: '%s'
+receivers:
+  - name: 'ops'
+    provider: 'telegram'
+    to: ['123']
+    templates: ['%s']
+`, templateFile, providerFile, tokenFile, receiverFile))
+
+	c, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, c)
+
+	for _, file := range []string{filename, tokenFile, templateFile, providerFile, receiverFile} {
+		sum := configChecksum(filename)
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString("\n"); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		assert.NotEqual(t, sum, configChecksum(filename), file)
+	}
+}
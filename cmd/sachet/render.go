@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/messagebird/sachet/sms"
+)
+
+// renderResult is the outcome of rendering a template.
+type renderResult struct {
+	Output     string       `json:"output"`
+	Characters int          `json:"characters"`
+	Encoding   sms.Encoding `json:"encoding"`
+	Segments   int          `json:"segments"`
+}
+
+// renderTemplate renders text with t against data in locale l. A nil data
+// renders the built-in sample notification.
+func renderTemplate(t *templates, text string, data *notification, l *locale) (renderResult, error) {
+	if data == nil {
+		sample := sampleData("sample")
+		data = &sample
+	}
+
+	output, err := t.execute(text, data, l)
+	if err != nil {
+		return renderResult{}, err
+	}
+
+	count := sms.Measure(output)
+	return renderResult{
+		Output:     output,
+		Characters: count.Characters,
+		Encoding:   count.Encoding,
+		Segments:   count.Segments,
+	}, nil
+}
+
+// runTemplate implements the template command and returns the exit code.
+func runTemplate(args []string) int {
+	if len(args) == 0 || args[0] != "render" {
+		fmt.Fprintln(os.Stderr, "Usage: template render [flags]")
+		return 2
+	}
+
+	var (
+		text     string
+		textFile string
+		dataFile string
+		globs    listFlag
+	)
+	fs := flag.NewFlagSet("template render", flag.ContinueOnError)
+	fs.StringVar(&text, "template", "", "Template text to render, e.g. '{{ template \"telegram_text\" . }}'.")
+	fs.StringVar(&textFile, "template-file", "", "File holding the template text to render.")
+	fs.Var(&globs, "templates", "Glob of template files to load, may be repeated.")
+	fs.StringVar(&dataFile, "data", "", "Alertmanager webhook JSON file to render, or - for stdin. Defaults to a sample with firing and resolved alerts.")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := renderCommand(text, textFile, dataFile, globs, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func renderCommand(text, textFile, dataFile string, globs []string, stdin io.Reader, stdout io.Writer) error {
+	if (text == "") == (textFile == "") {
+		return errors.New("exactly one of -template and -template-file is required")
+	}
+	if textFile != "" {
+		content, err := ioutil.ReadFile(textFile)
+		if err != nil {
+			return err
+		}
+		text = string(content)
+	}
+
+	var data *notification
+	if dataFile != "" {
+		d, err := readAlerts(dataFile, stdin)
+		if err != nil {
+			return err
+		}
+		data = &d
+	}
+
+	t, err := newTemplates(globs...)
+	if err != nil {
+		return err
+	}
+
+	result, err := renderTemplate(t, text, data, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, result.Output)
+	fmt.Fprintln(stdout, "---")
+	fmt.Fprintf(stdout, "Characters: %d\n", result.Characters)
+	fmt.Fprintf(stdout, "Encoding:   %s\n", result.Encoding)
+	fmt.Fprintf(stdout, "Segments:   %d\n", result.Segments)
+	return nil
+}
+
+// renderRequest is the body of a template render request.
+type renderRequest struct {
+	// Template is the template text to render.
+	Template string `json:"template"`
+	// Definitions are extra template sources, typically {{ define }} blocks,
+	// available to Template next to the configured templates.
+	Definitions []string `json:"definitions"`
+	// Data is the notification to render. Defaults to a sample with firing
+	// and resolved alerts.
+	Data *notification `json:"data"`
+	// Receiver renders with the templates, locale and time zone of that
+	// receiver, as its notifications are.
+	Receiver string `json:"receiver"`
+	// Locale overrides the locale of the receiver, to render the message of
+	// its contacts with a locale of their own.
+	Locale string `json:"locale"`
+}
+
+// RenderTemplate renders a template against a notification using the
+// configured templates, or those of a receiver, so templates can be tried
+// without triggering alerts.
+// Unlike the template render command it does not accept template globs: the
+// server never reads files named by the request.
+func (h handlers) RenderTemplate(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req renderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Template == "" {
+		writeError(w, r, http.StatusBadRequest, errors.New("template missing"))
+		return
+	}
+
+	c := currentConfig()
+	t, l, err := c.renderTarget(req.Receiver, req.Locale)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// execute parses the text as a single template, so {{ define }} blocks
+	// in front of it are added to the templates it can call.
+	text := strings.Join(append(req.Definitions, req.Template), "")
+	result, err := renderTemplate(t, text, req.Data, l)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logger(r.Context()).Error("Error writing response", "err", err)
+	}
+}
+
+// renderTarget returns the templates and the locale the named receiver
+// renders its messages with, in the locale tag instead of its own if set.
+// Without a receiver, they are the templates shared by every receiver.
+func (c *loadedConfig) renderTarget(receiver, tag string) (*templates, *locale, error) {
+	if receiver == "" {
+		if tag == "" {
+			return c.tmpl, nil, nil
+		}
+		l, err := c.config.newLocale(tag, "")
+		return c.tmpl, l, err
+	}
+
+	rc := c.config.receiverConfByReceiver(receiver)
+	if rc == nil {
+		return nil, nil, withCode(codeUnknownReceiver, fmt.Errorf("receiver missing: %q", receiver))
+	}
+	if tag == "" {
+		tag = rc.Locale
+	}
+	l, err := c.config.newLocale(tag, rc.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.templatesFor(rc), l, nil
+}
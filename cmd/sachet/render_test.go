@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RenderTemplate(t *testing.T) {
+	c, _ := newTestConfig(t, ReceiverConf{Name: "ops", Provider: "recording", Locale: "fr"})
+	filename := filepath.Join(t.TempDir(), "ops.tmpl")
+	content := `{{ define "title" }}{{ tr "FIRING" }} {{ .CommonLabels.alertname }}{{ end }}` +
+		`{{ define "title.fr" }}Alerte {{ .CommonLabels.alertname }}{{ end }}`
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c.config.Receivers[0].Templates = []string{filename}
+	var err error
+	if c.receiverTmpls, err = c.config.receiverTemplates(c.tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		exp    string
+	}{
+		{
+			name:   "sample data",
+			body:   `{"template": "{{ len .Alerts.Firing }} firing, {{ len .Alerts.Resolved }} resolved"}`,
+			status: http.StatusOK,
+			exp:    `{"output":"1 firing, 1 resolved","characters":20,"encoding":"GSM-7","segments":1}` + "\n",
+		},
+		{
+			name: "definitions and data",
+			body: `{
+				"definitions": ["{{ define \"title\" }}[{{ .Status | toUpper }}] {{ .CommonLabels.alertname }}{{ end }}"],
+				"template": "{{ template \"title\" . }}",
+				"data": {"status": "resolved", "commonLabels": {"alertname": "Ünavailable"}}
+			}`,
+			status: http.StatusOK,
+			exp:    `{"output":"[RESOLVED] Ünavailable","characters":22,"encoding":"GSM-7","segments":1}` + "\n",
+		},
+		{
+			name:   "receiver templates and locale",
+			body:   `{"receiver": "ops", "template": "{{ template \"title\" . }}", "data": {"commonLabels": {"alertname": "Down"}}}`,
+			status: http.StatusOK,
+			exp:    `{"output":"Alerte Down","characters":11,"encoding":"GSM-7","segments":1}` + "\n",
+		},
+		{
+			name:   "locale of a contact",
+			body:   `{"receiver": "ops", "locale": "en", "template": "{{ template \"title\" . }}", "data": {"commonLabels": {"alertname": "Down"}}}`,
+			status: http.StatusOK,
+			exp:    `{"output":"FIRING Down","characters":11,"encoding":"GSM-7","segments":1}` + "\n",
+		},
+		{
+			name:   "receiver templates are not shared",
+			body:   `{"template": "{{ template \"title\" . }}"}`,
+			status: http.StatusUnprocessableEntity,
+			exp: `{"Error":true,"Status":422,"Message":"template: :1:12: executing \"\" at \u003c{{template \"title\" .}}\u003e: ` +
+				`template \"title\" not defined"}`,
+		},
+		{
+			name:   "unknown receiver",
+			body:   `{"receiver": "dev", "template": "{{ .Status }}"}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"unknown_receiver","Message":"receiver missing: \"dev\""}`,
+		},
+		{
+			name:   "missing template",
+			body:   `{}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Message":"template missing"}`,
+		},
+		{
+			name:   "invalid template",
+			body:   `{"template": "{{ template \"missing\" . }}"}`,
+			status: http.StatusUnprocessableEntity,
+			exp: `{"Error":true,"Status":422,"Message":"template: :1:12: executing \"\" at \u003c{{template \"missing\" .}}\u003e: ` +
+				`template \"missing\" not defined"}`,
+		},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/template/render", strings.NewReader(tc.body))
+		handlers{}.RenderTemplate(w, r)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		assert.Equal(t, tc.exp, w.Body.String(), tc.name)
+	}
+}
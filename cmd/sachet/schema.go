@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schema is a JSON Schema document.
+type schema map[string]interface{}
+
+// configSchema returns the JSON Schema of the configuration file, generated
+// from the configuration struct so that it cannot drift from the keys
+// LoadConfig accepts. Like LoadConfig, it rejects unknown keys.
+func configSchema() schema {
+	s := typeSchema(reflect.TypeOf(Config{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "Sachet configuration"
+
+	receiver := s["properties"].(map[string]schema)["receivers"]["items"].(schema)
+	receiver["properties"].(map[string]schema)["provider"]["enum"] = providerNames()
+	receiver["required"] = []string{"name", "provider"}
+
+	healthChecks := s["properties"].(map[string]schema)["health_checks"]
+	healthChecks["properties"].(map[string]schema)["readiness"]["items"].(schema)["enum"] = providerNames()
+
+	return s
+}
+
+// configSchemaJSON returns the indented JSON encoding of configSchema.
+func configSchemaJSON() ([]byte, error) {
+	b, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func typeSchema(t reflect.Type) schema {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return schema{"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]schema{}
+		addProperties(properties, t)
+		return schema{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	}
+	return schema{}
+}
+
+// addProperties adds the schema of every YAML key of struct type t.
+func addProperties(properties map[string]schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, inline, ok := yamlKey(field)
+		if !ok {
+			continue
+		}
+		if inline {
+			addProperties(properties, field.Type)
+			continue
+		}
+		properties[key] = typeSchema(field.Type)
+	}
+}
+
+// yamlKey returns the key yaml.v2 decodes field from, and whether the field
+// is inlined. ok is false for fields that are not decoded.
+func yamlKey(field reflect.StructField) (key string, inline, ok bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false, false
+	}
+
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return "", true, true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], false, true
+	}
+	return strings.ToLower(field.Name), false, true
+}
+
+// providerNames returns the names of the providers that can be configured.
+func providerNames() []string {
+	t := reflect.TypeOf(Config{}.Providers)
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key, _, ok := yamlKey(t.Field(i)); ok {
+			names = append(names, key)
+		}
+	}
+	return names
+}
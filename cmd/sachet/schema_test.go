@@ -0,0 +1,23 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_configSchema makes sure the published schema matches the configuration
+// struct. Regenerate it with: sachet -print-config-schema > examples/config.schema.json.
+func Test_configSchema(t *testing.T) {
+	t.Parallel()
+
+	published, err := ioutil.ReadFile("../../examples/config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := configSchemaJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), string(generated))
+}
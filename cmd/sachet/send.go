@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/messagebird/sachet"
+)
+
+// listFlag is a flag that can be repeated or given a comma separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// sendOptions holds the flags of the send command.
+type sendOptions struct {
+	receiver string
+	provider string
+	to       listFlag
+	from     string
+	msgType  string
+	text     string
+	alerts   string
+	dryRun   bool
+}
+
+// runSend implements the send command: it sends a single message through a
+// configured receiver or provider and returns the exit code.
+func runSend(args []string) int {
+	var opts sendOptions
+
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.StringVar(&opts.receiver, "receiver", "", "Name of the receiver to send through. Defaults to the receiver of the -alerts payload.")
+	fs.StringVar(&opts.provider, "provider", "", "Name of the provider to send through instead of a receiver. Requires -to.")
+	fs.Var(&opts.to, "to", "Recipient, may be repeated or comma separated. Overrides the recipients of the receiver.")
+	fs.StringVar(&opts.from, "from", "", "Sender. Overrides the sender of the receiver.")
+	fs.StringVar(&opts.msgType, "type", "", "Message type. Overrides the type of the receiver.")
+	fs.StringVar(&opts.text, "text", "", "Raw text to send.")
+	fs.StringVar(&opts.alerts, "alerts", "", "Alertmanager webhook JSON file to render and send, or - for stdin.")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Print the message instead of sending it.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := LoadConfig(*configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 1
+	}
+
+	if err := send(opts, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func send(opts sendOptions, stdin io.Reader, stdout io.Writer) error {
+	if (opts.text == "") == (opts.alerts == "") {
+		return errors.New("exactly one of -text and -alerts is required")
+	}
+
+	var data notification
+	if opts.alerts != "" {
+		var err error
+		if data, err = readAlerts(opts.alerts, stdin); err != nil {
+			return err
+		}
+	}
+
+	name := opts.receiver
+	if name == "" && opts.provider == "" {
+		name = data.Receiver
+	}
+	c := currentConfig()
+	receiverConf, provider, err := c.target(name, opts.provider, opts.to, opts.from, opts.msgType)
+	if err != nil {
+		return err
+	}
+	if opts.provider == "" {
+		data.Receiver = receiverConf.Name
+	}
+
+	var messages []sachet.Message
+	if opts.alerts != "" {
+		if messages, err = c.newMessages(&receiverConf, provider, data); err != nil {
+			return err
+		}
+	} else {
+		messages = []sachet.Message{newTextMessage(&receiverConf, provider, opts.text)}
+	}
+
+	if opts.dryRun {
+		for i, message := range messages {
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			printMessage(stdout, receiverConf.Provider, message)
+		}
+		return nil
+	}
+
+	recipients := 0
+	for _, message := range messages {
+		if err := provider.Send(message); err != nil {
+			return err
+		}
+		recipients += len(message.To)
+	}
+	fmt.Fprintf(stdout, "Sent to %d recipient(s) through %s\n", recipients, receiverConf.Provider)
+	return nil
+}
+
+// target returns the configuration of the receiver with that name, or of an
+// ad hoc receiver of the named provider, with its recipients, sender and type
+// overridden by the non-empty arguments. The recipients are validated and
+// normalised for the provider, which is returned too.
+func (c *loadedConfig) target(receiver, provider string, to []string, from, msgType string) (ReceiverConf, sachet.Provider, error) {
+	var rc ReceiverConf
+	switch {
+	case provider != "" && receiver != "":
+		return rc, nil, errors.New("receiver and provider are mutually exclusive")
+	case provider != "":
+		if len(to) == 0 {
+			return rc, nil, errors.New("provider requires recipients")
+		}
+		rc = ReceiverConf{Name: provider, Provider: provider}
+	default:
+		conf := c.config.receiverConfByReceiver(receiver)
+		if conf == nil {
+			return rc, nil, fmt.Errorf("receiver missing: %q", receiver)
+		}
+		rc = *conf
+		rc.To = append([]string(nil), conf.To...)
+		rc.Contacts = append([]ContactConf(nil), conf.Contacts...)
+	}
+
+	if len(to) > 0 {
+		rc.To = append([]string(nil), to...)
+		rc.Contacts = nil
+	}
+	if from != "" {
+		rc.From = from
+	}
+	if msgType != "" {
+		rc.Type = msgType
+	}
+
+	// Reuses the provider in use, so that the overrides are validated
+	// against it, and creates the provider of an ad hoc receiver otherwise.
+	p, ok := c.providers[rc.Provider]
+	if !ok {
+		var err error
+		if p, err = c.config.newProvider(&rc); err != nil {
+			return rc, nil, err
+		}
+	}
+	// The recipients of a configured receiver were normalised when the
+	// configuration was loaded, and some formats cannot be normalised twice.
+	if len(to) > 0 {
+		if err := c.config.normalizeRecipients(&rc, p); err != nil {
+			return rc, nil, fmt.Errorf("receiver %q: %w", rc.Name, err)
+		}
+	}
+	if err := checkReceiver(&rc, p); err != nil {
+		return rc, nil, err
+	}
+	return rc, p, nil
+}
+
+// readAlerts decodes an Alertmanager webhook payload from filename, or from
+// stdin if filename is "-".
+func readAlerts(filename string, stdin io.Reader) (notification, error) {
+	var data notification
+
+	r := stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return data, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return data, fmt.Errorf("decoding %s: %w", filename, err)
+	}
+	return data, nil
+}
+
+// printMessage writes what would be sent through provider to w.
+func printMessage(w io.Writer, provider string, message sachet.Message) {
+	fmt.Fprintf(w, "Provider: %s\n", provider)
+	fmt.Fprintf(w, "To:       %s\n", strings.Join(message.To, ", "))
+	if message.From != "" {
+		fmt.Fprintf(w, "From:     %s\n", message.From)
+	}
+	if message.Type != "" {
+		fmt.Fprintf(w, "Type:     %s\n", message.Type)
+	}
+	fmt.Fprintf(w, "Text:\n%s\n", message.Text)
+}
+
+// sendRequest is the body of a send API request.
+type sendRequest struct {
+	// Receiver is the receiver to send through, unless Provider is set.
+	Receiver string `json:"receiver"`
+	// Provider is the provider to send through to To, without a receiver.
+	Provider string `json:"provider"`
+	// To, From and Type override those of the receiver.
+	To   []string `json:"to"`
+	From string   `json:"from"`
+	Type string   `json:"type"`
+	// Text is sent as is, truncated to the length the provider accepts.
+	Text string `json:"text"`
+	// Metadata is logged with the message, to tell where it came from.
+	Metadata map[string]string `json:"metadata"`
+}
+
+// sendResult is the outcome of a send API request for one recipient.
+type sendResult struct {
+	Recipient string `json:"recipient"`
+	Status    string `json:"status"`
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// sendResponse is the body of a send API response.
+type sendResponse struct {
+	Receiver string       `json:"receiver"`
+	Provider string       `json:"provider"`
+	Results  []sendResult `json:"results"`
+}
+
+// sendTarget validates req and returns the receiver and provider it sends
+// through. Only the providers of the configured receivers are used, so that
+// requests share their clients, health checks and instrumentation.
+func (c *loadedConfig) sendTarget(req sendRequest) (ReceiverConf, sachet.Provider, error) {
+	switch {
+	case req.Text == "":
+		return ReceiverConf{}, nil, withCode(codeInvalidPayload, errors.New("text missing"))
+	case req.Receiver == "" && req.Provider == "":
+		return ReceiverConf{}, nil, withCode(codeInvalidPayload, errors.New("receiver or provider required"))
+	case req.Receiver == "" && c.providers[req.Provider] == nil:
+		return ReceiverConf{}, nil, withCode(codeUnusedProvider, fmt.Errorf("provider %q is not used by any receiver", req.Provider))
+	case req.Provider == "" && c.config.receiverConfByReceiver(req.Receiver) == nil:
+		return ReceiverConf{}, nil, withCode(codeUnknownReceiver, fmt.Errorf("receiver missing: %q", req.Receiver))
+	}
+
+	rc, provider, err := c.target(req.Receiver, req.Provider, req.To, req.From, req.Type)
+	if err != nil {
+		return rc, nil, withCode(codeInvalidPayload, err)
+	}
+	return rc, provider, nil
+}
+
+// Send sends a text through a configured receiver or provider, for senders
+// other than Alertmanager. The message goes through the circuit breaker and
+// metrics of alerts, and the response gives the outcome of every recipient.
+func (h handlers) Send(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req sendRequest
+	err := checkContentType(r)
+	if err == nil {
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			err = decodeError(err)
+		}
+	}
+	if err != nil {
+		errorHandler(w, r, errorStatus(err), err, "?")
+		return
+	}
+
+	c := currentConfig()
+	receiverConf, provider, err := c.sendTarget(req)
+	if err != nil {
+		errorHandler(w, r, errorStatus(err), err, "?")
+		return
+	}
+
+	keys := make([]string, 0, len(req.Metadata))
+	for k := range req.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	metadata := make([]any, 0, 2*len(keys))
+	for _, k := range keys {
+		metadata = append(metadata, k, req.Metadata[k])
+	}
+	ctx := withLogger(r.Context(), logger(r.Context()).With(
+		"receiver", receiverConf.Name,
+		"provider", receiverConf.Provider,
+		slog.Group("metadata", metadata...),
+	))
+	r = r.WithContext(ctx)
+
+	message := newTextMessage(&receiverConf, provider, req.Text)
+	results, err := sendMessage(ctx, c, receiverConf.Provider, provider, message)
+	observeMessage(receiverConf.Name, receiverConf.Provider, message, results, err)
+	if results == nil {
+		results = make([]sachet.Result, len(message.To))
+		for i, recipient := range message.To {
+			results[i] = sachet.Result{Recipient: recipient, Err: err}
+		}
+	}
+
+	resp := sendResponse{Receiver: receiverConf.Name, Provider: receiverConf.Provider}
+	for _, result := range results {
+		sr := sendResult{Recipient: result.Recipient, Status: "sent", MessageID: result.MessageID}
+		if result.Err != nil {
+			sr.Status = "failed"
+			sr.Error = result.Err.Error()
+		}
+		resp.Results = append(resp.Results, sr)
+	}
+
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, errRateLimited):
+		status = http.StatusTooManyRequests
+	case err != nil:
+		status = http.StatusBadGateway
+	}
+	if err != nil {
+		logger(ctx).Error("Sending failed", "recipients", len(message.To), "err", err)
+	} else {
+		logger(ctx).Info("Sent message", "recipients", len(message.To))
+	}
+	requestTotal.WithLabelValues(strconv.Itoa(status), receiverConf.Provider).Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger(ctx).Error("Error writing response", "err", err)
+	}
+}
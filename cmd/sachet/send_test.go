@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+func Test_sendDryRun(t *testing.T) {
+	restoreConfig(t)
+
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+default_country: NL
+providers:
+  nexmo:
+    api_key: 'key'
+    api_secret: 'secret'
+receivers:
+  - name: 'team-sms'
+    provider: 'messagebird'
+    from: 'sachet'
+    to: ['06 12345678']
+    text: '{{ .Status | toUpper }}: {{ .CommonLabels.alertname }}'
+  - name: 'team-nexmo'
+    provider: 'nexmo'
+    to: ['+4915112345678']
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		opts  sendOptions
+		stdin string
+		exp   string
+		err   string
+	}{
+		{
+			name: "receiver text",
+			opts: sendOptions{receiver: "team-sms", text: "hello", dryRun: true},
+			exp:  "Provider: messagebird\nTo:       +31612345678\nFrom:     sachet\nText:\nhello\n",
+		},
+		{
+			name:  "alerts from stdin",
+			opts:  sendOptions{alerts: "-", dryRun: true},
+			stdin: `{"receiver": "team-sms", "status": "firing", "commonLabels": {"alertname": "InstanceDown"}}`,
+			exp:   "Provider: messagebird\nTo:       +31612345678\nFrom:     sachet\nText:\nFIRING: InstanceDown\n",
+		},
+		{
+			name: "provider with recipients",
+			opts: sendOptions{provider: "twilio", to: listFlag{"0687654321"}, text: "hello", dryRun: true},
+			exp:  "Provider: twilio\nTo:       +31687654321\nText:\nhello\n",
+		},
+		{
+			name: "receiver normalised at load",
+			opts: sendOptions{receiver: "team-nexmo", text: "hello", dryRun: true},
+			exp:  "Provider: nexmo\nTo:       4915112345678\nText:\nhello\n",
+		},
+		{
+			name: "recipient override normalised",
+			opts: sendOptions{receiver: "team-nexmo", to: listFlag{"+4915187654321"}, text: "hello", dryRun: true},
+			exp:  "Provider: nexmo\nTo:       4915187654321\nText:\nhello\n",
+		},
+		{
+			name: "invalid recipient",
+			opts: sendOptions{receiver: "team-sms", to: listFlag{"12"}, text: "hello", dryRun: true},
+			err:  `receiver "team-sms": invalid phone number "12": too short`,
+		},
+		{
+			name: "missing text",
+			opts: sendOptions{receiver: "team-sms", dryRun: true},
+			err:  "exactly one of -text and -alerts is required",
+		},
+	}
+	for _, tc := range cases {
+		var stdout bytes.Buffer
+		err := send(tc.opts, strings.NewReader(tc.stdin), &stdout)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.exp, stdout.String(), tc.name)
+	}
+}
+
+// resultProvider fails to send to the recipients in failing.
+type resultProvider struct {
+	failing map[string]bool
+}
+
+func (p resultProvider) Send(message sachet.Message) error {
+	_, err := p.SendResults(context.Background(), message)
+	return err
+}
+
+func (p resultProvider) SendResults(ctx context.Context, message sachet.Message) ([]sachet.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var results []sachet.Result
+	for _, to := range message.To {
+		if p.failing[to] {
+			results = append(results, sachet.Result{Recipient: to, Err: errors.New("unknown subscriber")})
+		} else {
+			results = append(results, sachet.Result{Recipient: to, MessageID: "id-" + to})
+		}
+	}
+	return results, nil
+}
+
+func Test_Send(t *testing.T) {
+	c, _ := newTestConfig(t,
+		ReceiverConf{Name: "ops", Provider: "reporting", To: []string{"1", "2"}},
+		ReceiverConf{Name: "plain", Provider: "plain", To: []string{"1"}},
+	)
+	c.providers["reporting"] = resultProvider{failing: map[string]bool{"3": true}}
+	c.providers["plain"] = fakeProvider{}
+	c.providers["limited"] = fakeProvider{}
+	c.config.RateLimits = map[string]RateLimitConf{"limited": {Rate: 0.001}}
+	rateLimiters.Lock()
+	delete(rateLimiters.byName, "limited")
+	rateLimiters.Unlock()
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		status      int
+		exp         string
+	}{
+		{
+			name:   "receiver",
+			body:   `{"receiver": "ops", "text": "backup failed", "metadata": {"job": "backup"}}`,
+			status: http.StatusOK,
+			exp:    `{"receiver":"ops","provider":"reporting","results":[{"recipient":"1","status":"sent","message_id":"id-1"},{"recipient":"2","status":"sent","message_id":"id-2"}]}`,
+		},
+		{
+			name:   "recipient failing",
+			body:   `{"receiver": "ops", "to": ["1", "3"], "text": "backup failed"}`,
+			status: http.StatusBadGateway,
+			exp:    `{"receiver":"ops","provider":"reporting","results":[{"recipient":"1","status":"sent","message_id":"id-1"},{"recipient":"3","status":"failed","error":"unknown subscriber"}]}`,
+		},
+		{
+			name:   "provider without message IDs",
+			body:   `{"provider": "plain", "to": ["4"], "text": "backup failed"}`,
+			status: http.StatusOK,
+			exp:    `{"receiver":"plain","provider":"plain","results":[{"recipient":"4","status":"sent"}]}`,
+		},
+		{
+			name:   "rate limit",
+			body:   `{"provider": "limited", "to": ["4"], "text": "backup failed"}`,
+			status: http.StatusOK,
+			exp:    `{"receiver":"limited","provider":"limited","results":[{"recipient":"4","status":"sent"}]}`,
+		},
+		{
+			name:   "rate limit exceeded",
+			body:   `{"provider": "limited", "to": ["4"], "text": "backup failed"}`,
+			status: http.StatusTooManyRequests,
+			exp:    `{"receiver":"limited","provider":"limited","results":[{"recipient":"4","status":"failed","error":"rate limit of the provider exceeded, not sending"}]}`,
+		},
+		{
+			name:   "provider without recipients",
+			body:   `{"provider": "plain", "text": "backup failed"}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"invalid_payload","Message":"provider requires recipients"}`,
+		},
+		{
+			name:   "unknown receiver",
+			body:   `{"receiver": "dev", "text": "backup failed"}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"unknown_receiver","Message":"receiver missing: \"dev\""}`,
+		},
+		{
+			name:   "missing text",
+			body:   `{"receiver": "ops"}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"invalid_payload","Message":"text missing"}`,
+		},
+		{
+			name:   "provider not in use",
+			body:   `{"provider": "twilio", "to": ["4"], "text": "backup failed"}`,
+			status: http.StatusBadRequest,
+			exp:    `{"Error":true,"Status":400,"Code":"unused_provider","Message":"provider \"twilio\" is not used by any receiver"}`,
+		},
+		{
+			name:        "form body",
+			body:        `receiver=ops&text=backup+failed`,
+			contentType: "application/x-www-form-urlencoded",
+			status:      http.StatusUnsupportedMediaType,
+			exp:         `{"Error":true,"Status":415,"Code":"unsupported_media_type","Message":"unsupported content type \"application/x-www-form-urlencoded\", expected application/json"}`,
+		},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/send", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		handlers{}.Send(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		assert.JSONEq(t, tc.exp, w.Body.String(), tc.name)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(recipientsTotal.WithLabelValues("ops", "reporting", "failure")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(recipientsTotal.WithLabelValues("ops", "reporting", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(recipientsTotal.WithLabelValues("limited", "limited", "rate_limited")))
+}
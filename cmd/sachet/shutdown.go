@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// shuttingDown is set to 1 once the server started shutting down.
+var shuttingDown int32
+
+// shutdownCheck is a readiness check failing once the server started shutting
+// down, so that load balancers stop routing new notifications to it.
+func shutdownCheck() error {
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		return errors.New("shutting down")
+	}
+	return nil
+}
+
+// serve accepts connections on l until a signal is received on stop. It then
+// fails the readiness check, keeps serving for delay, and stops accepting new
+// connections while requests in flight, such as sends to providers, complete.
+// Requests still running after timeout are aborted.
+func serve(server *http.Server, l net.Listener, stop <-chan os.Signal, delay, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			errc <- server.ServeTLS(l, "", "")
+		} else {
+			errc <- server.Serve(l)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		slog.Info("Shutting down", "signal", sig)
+	}
+
+	atomic.StoreInt32(&shuttingDown, 1)
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return fmt.Errorf("requests in flight did not complete within %s: %w", timeout, err)
+	}
+
+	slog.Info("Shut down")
+	return nil
+}
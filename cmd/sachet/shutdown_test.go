@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serve_drainsRequests(t *testing.T) {
+	defer atomic.StoreInt32(&shuttingDown, 0)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("sent"))
+	})}
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serve(server, l, stop, 0, 5*time.Second) }()
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/alert")
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	assert.Eventually(t, func() bool { return shutdownCheck() != nil }, time.Second, time.Millisecond)
+
+	// The server no longer accepts connections, but lets the request in
+	// flight complete.
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "sent", <-response)
+	assert.NoError(t, <-done)
+}
+
+func Test_serve_timeout(t *testing.T) {
+	defer atomic.StoreInt32(&shuttingDown, 0)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serve(server, l, stop, 0, 10*time.Millisecond) }()
+	go http.Get("http://" + l.Addr().String() + "/alert")
+
+	<-started
+	stop <- syscall.SIGTERM
+	assert.EqualError(t, <-done, "requests in flight did not complete within 10ms: context deadline exceeded")
+}
@@ -1,6 +1,14 @@
 package main
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/sms"
+)
 
 var requestTotal = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
@@ -10,6 +18,158 @@ var requestTotal = prometheus.NewCounterVec(
 	[]string{"code", "provider"},
 )
 
+var requestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "sachet_request_duration_seconds",
+		Help:    "Time taken to handle alert and send requests, from decoding to the provider response, partitioned by handler and status code.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	},
+	[]string{"handler", "code"},
+)
+
+var providerSendDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "sachet_provider_send_duration_seconds",
+		Help:    "Time taken by providers to send a message, partitioned by provider and outcome.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30},
+	},
+	[]string{"provider", "outcome"},
+)
+
+var messagesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sachet_messages_total",
+		Help: "How many messages were sent, partitioned by receiver, provider and outcome.",
+	},
+	[]string{"receiver", "provider", "outcome"},
+)
+
+var recipientsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sachet_recipients_total",
+		Help: "How many recipients messages were sent to, partitioned by receiver, provider and outcome.",
+	},
+	[]string{"receiver", "provider", "outcome"},
+)
+
+var notificationAlerts = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "sachet_notification_alerts",
+		Help:    "Number of alerts per notification, partitioned by receiver.",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100},
+	},
+	[]string{"receiver"},
+)
+
+var templateErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sachet_template_errors_total",
+		Help: "How many times rendering the text of a receiver failed, partitioned by receiver.",
+	},
+	[]string{"receiver"},
+)
+
+var messageCharacters = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "sachet_message_characters",
+		Help:    "Length of the messages delivered, in characters, partitioned by provider.",
+		Buckets: []float64{70, 160, 306, 459, 612, 1000, 1600, 4096},
+	},
+	[]string{"provider"},
+)
+
+var messageSegments = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "sachet_message_segments",
+		Help:    "Number of SMS segments of the messages delivered, partitioned by provider.",
+		Buckets: prometheus.LinearBuckets(1, 1, 10),
+	},
+	[]string{"provider"},
+)
+
+var configLastReloadSuccessful = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "sachet_config_last_reload_successful",
+		Help: "Whether the last configuration reload attempt was successful.",
+	},
+)
+
+var configLastReloadSuccessTimestamp = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "sachet_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful configuration reload.",
+	},
+)
+
+var providerUp = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sachet_provider_up",
+		Help: "Whether the last health check of the provider succeeded.",
+	},
+	[]string{"provider"},
+)
+
+var providerCircuitBreakerState = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sachet_provider_circuit_breaker_state",
+		Help: "State of the circuit breaker of the provider: 0 closed, 1 half-open, 2 open.",
+	},
+	[]string{"provider"},
+)
+
 func init() {
 	prometheus.MustRegister(requestTotal)
+	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(providerSendDuration)
+	prometheus.MustRegister(messagesTotal)
+	prometheus.MustRegister(recipientsTotal)
+	prometheus.MustRegister(notificationAlerts)
+	prometheus.MustRegister(templateErrorsTotal)
+	prometheus.MustRegister(messageCharacters)
+	prometheus.MustRegister(messageSegments)
+	prometheus.MustRegister(configLastReloadSuccessful)
+	prometheus.MustRegister(configLastReloadSuccessTimestamp)
+	prometheus.MustRegister(providerUp)
+	prometheus.MustRegister(providerCircuitBreakerState)
+}
+
+// outcome is the value of the outcome label for a send that returned err.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, errCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, errRateLimited):
+		return "rate_limited"
+	default:
+		return "failure"
+	}
+}
+
+// observeSend records how long provider took to send a message.
+func observeSend(provider string, start time.Time, err error) {
+	providerSendDuration.WithLabelValues(provider, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// observeMessage records a message sent to receiver through provider, with
+// the results of its recipients if the send was attempted. Its size is only
+// recorded if it reached a recipient.
+func observeMessage(receiver, provider string, message sachet.Message, results []sachet.Result, err error) {
+	messagesTotal.WithLabelValues(receiver, provider, outcome(err)).Inc()
+	if results == nil {
+		recipientsTotal.WithLabelValues(receiver, provider, outcome(err)).Add(float64(len(message.To)))
+	}
+	delivered := err == nil
+	for _, r := range results {
+		recipientsTotal.WithLabelValues(receiver, provider, outcome(r.Err)).Inc()
+		delivered = delivered || r.Err == nil
+	}
+	if !delivered {
+		return
+	}
+
+	count := sms.Measure(message.Text)
+	messageCharacters.WithLabelValues(provider).Observe(float64(count.Characters))
+	messageSegments.WithLabelValues(provider).Observe(float64(count.Segments))
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+func Test_observeMessage_size(t *testing.T) {
+	series := func() int { return testutil.CollectAndCount(messageCharacters) }
+	message := sachet.Message{To: []string{"1", "2"}, Text: "CPU high"}
+
+	// Messages that reached no recipient are not measured.
+	before := series()
+	observeMessage("size", "size-failed", message, nil, assert.AnError)
+	observeMessage("size", "size-failed", message, []sachet.Result{{Recipient: "1", Err: assert.AnError}, {Recipient: "2", Err: assert.AnError}}, assert.AnError)
+	assert.Equal(t, before, series())
+
+	observeMessage("size", "size-partial", message, []sachet.Result{{Recipient: "1"}, {Recipient: "2", Err: assert.AnError}}, assert.AnError)
+	assert.Equal(t, before+1, series())
+}
+
+func Test_instrumentDuration(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	before := testutil.CollectAndCount(requestDuration)
+
+	// Every handler has series of its own.
+	for _, handler := range []string{"/test-alert", "/test-send", "/test-send"} {
+		instrumentDuration(handler, ok).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, handler, nil))
+	}
+	assert.Equal(t, before+2, testutil.CollectAndCount(requestDuration))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	tmpltext "text/template"
+	"time"
+
+	"github.com/prometheus/alertmanager/asset"
+	"github.com/prometheus/alertmanager/template"
+
+	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/sms"
+)
+
+// embeddedTemplates are the templates shipped with sachet. default.tmpl is
+// available to every receiver, and <provider>.tmpl to the receivers of that
+// provider only, where it can redefine the templates of default.tmpl.
+//
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS
+
+// templates is a set of named templates, and the functions they can call,
+// that receivers render messages with. It plays the part of the Alertmanager
+// template.Template, which can neither be given functions nor be extended
+// with templates for some receivers only.
+type templates struct {
+	text *tmpltext.Template
+}
+
+// newTemplates returns the Alertmanager default templates, the default
+// templates of sachet and those of the files matching globs.
+func newTemplates(globs ...string) (*templates, error) {
+	funcs := tmpltext.FuncMap{}
+	for name, f := range template.DefaultFuncs {
+		funcs[name] = f
+	}
+	for name, f := range templateFuncs {
+		funcs[name] = f
+	}
+	t := &templates{text: tmpltext.New("").Option("missingkey=zero").Funcs(funcs)}
+
+	f, err := asset.Assets.Open("/templates/default.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if t.text, err = t.text.Parse(string(b)); err != nil {
+		return nil, err
+	}
+	if t.text, err = t.text.ParseFS(embeddedTemplates, "templates/default.tmpl"); err != nil {
+		return nil, err
+	}
+	return t.parseGlobs(globs...)
+}
+
+// with returns a copy of t with the templates of the provider embedded in
+// sachet and those of the files matching globs added. Templates they define
+// replace the ones of t with the same name in the copy only. t itself is
+// returned if there is nothing to add.
+func (t *templates) with(provider string, globs ...string) (*templates, error) {
+	embedded := "templates/" + provider + ".tmpl"
+	_, err := fs.Stat(embeddedTemplates, embedded)
+	hasEmbedded := err == nil && provider != "default"
+	if !hasEmbedded && len(globs) == 0 {
+		return t, nil
+	}
+
+	text, err := t.text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	c := &templates{text: text}
+	if hasEmbedded {
+		if c.text, err = c.text.ParseFS(embeddedTemplates, embedded); err != nil {
+			return nil, err
+		}
+	}
+	return c.parseGlobs(globs...)
+}
+
+func (t *templates) parseGlobs(globs ...string) (*templates, error) {
+	for _, glob := range globs {
+		// ParseGlob fails if no file matches, while globs are allowed to
+		// match files created later on.
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			if t.text, err = t.text.ParseGlob(glob); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// ExecuteTextString renders text, which can call the templates of t, against
+// data.
+func (t *templates) ExecuteTextString(text string, data interface{}) (string, error) {
+	return t.execute(text, data, nil)
+}
+
+// execute is ExecuteTextString in locale l. The templates named after another
+// one followed by a dot and the locale, or its language, replace it: for the
+// fr-CA locale, sms_text.fr-CA is used in place of sms_text, or else
+// sms_text.fr.
+func (t *templates) execute(text string, data interface{}, l *locale) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := t.text.Clone()
+	if err != nil {
+		return "", err
+	}
+	if l != nil {
+		tmpl.Funcs(l.funcs())
+	}
+	if tmpl, err = tmpl.New("").Option("missingkey=zero").Parse(text); err != nil {
+		return "", err
+	}
+	if l != nil {
+		for _, tag := range l.tags() {
+			for _, variant := range tmpl.Templates() {
+				name := variant.Name()
+				if base := strings.TrimSuffix(name, "."+tag); base != name && base != "" {
+					if _, err := tmpl.AddParseTree(base, variant.Tree); err != nil {
+						return "", err
+					}
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
+// templateFuncs are the functions sachet adds to those of Alertmanager.
+var templateFuncs = tmpltext.FuncMap{
+	"truncate":         truncate,
+	"smsSafe":          sms.ToGSM7,
+	"since":            since,
+	"humanizeDuration": humanizeDuration,
+	"filterLabels":     filterLabels,
+	"excludeLabels":    excludeLabels,
+	"escapeMarkdown":   markdownEscaper.Replace,
+	"escapeMarkdownV2": markdownV2Escaper.Replace,
+	"escapeHTML":       html.EscapeString,
+	// tr and formatTime are bound to the locale of the recipients when
+	// rendering messages.
+	"tr": (*locale)(nil).translate,
+	"formatTime": func(t time.Time, layout ...string) string {
+		return (*locale)(nil).formatTime(t, strings.Join(layout, ""))
+	},
+}
+
+// truncate returns the first n characters of s.
+func truncate(n int, s string) string {
+	return sachet.Capabilities{MaxTextLength: n}.Truncate(s)
+}
+
+// since returns the time elapsed since t, such as 2h5m, or the empty string
+// for the zero time.
+func since(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return humanizeDuration(time.Since(t))
+}
+
+// humanizeDuration formats d with its two most significant units, such as
+// 45s, 5m10s, 2h5m or 3d4h.
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	d = d.Round(time.Second)
+
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	for i, u := range units {
+		if d < u.size && u.size != time.Second {
+			continue
+		}
+		s := fmt.Sprintf("%d%s", d/u.size, u.name)
+		if i+1 < len(units) {
+			next := units[i+1]
+			if n := d % u.size / next.size; n > 0 {
+				s += fmt.Sprintf("%d%s", n, next.name)
+			}
+		}
+		return s
+	}
+	return "0s"
+}
+
+// filterLabels returns the labels of kv with the given names.
+func filterLabels(kv template.KV, names ...string) template.KV {
+	res := template.KV{}
+	for _, name := range names {
+		if v, ok := kv[name]; ok {
+			res[name] = v
+		}
+	}
+	return res
+}
+
+// excludeLabels returns the labels of kv except those with the given names.
+func excludeLabels(kv template.KV, names ...string) template.KV {
+	return kv.Remove(names)
+}
+
+var (
+	// markdownEscaper escapes the Markdown of the Telegram Markdown parse
+	// mode, and of most chat services.
+	markdownEscaper = newEscaper("_*`[")
+	// markdownV2Escaper escapes the characters reserved by the Telegram
+	// MarkdownV2 parse mode.
+	markdownV2Escaper = newEscaper("\\_*[]()~`>#+-=|{}.!")
+)
+
+// newEscaper returns a replacer prefixing the given characters with \.
+func newEscaper(chars string) *strings.Replacer {
+	var oldnew []string
+	for _, c := range chars {
+		oldnew = append(oldnew, string(c), "\\"+string(c))
+	}
+	return strings.NewReplacer(oldnew...)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_templateFuncs(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := newTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := template.Data{
+		Alerts:       template.Alerts{{Status: "firing"}},
+		CommonLabels: template.KV{"alertname": "Disk_Full", "instance": "db-1", "job": "node"},
+		CommonAnnotations: template.KV{
+			"summary": "“Disk” is full – 99%",
+			"link":    "<a href=\"x\">",
+		},
+	}
+
+	cases := []struct {
+		text string
+		exp  string
+	}{
+		{text: `{{ .CommonLabels.alertname | truncate 4 }}`, exp: "Disk"},
+		{text: `{{ .CommonAnnotations.summary | smsSafe }}`, exp: `"Disk" is full - 99%`},
+		{text: `{{ range .Alerts }}{{ since .StartsAt }}{{ end }}`, exp: ""},
+		{text: `{{ (filterLabels .CommonLabels "job" "missing").Values }}`, exp: "[node]"},
+		{text: `{{ (excludeLabels .CommonLabels "job").Names }}`, exp: "[alertname instance]"},
+		{text: `{{ .CommonLabels.alertname | escapeMarkdown }}`, exp: `Disk\_Full`},
+		{text: `{{ "1.5 (a-b)" | escapeMarkdownV2 }}`, exp: `1\.5 \(a\-b\)`},
+		{text: `{{ .CommonAnnotations.link | escapeHTML }}`, exp: "&lt;a href=&#34;x&#34;&gt;"},
+		{text: `{{ .CommonLabels.alertname | toUpper }}`, exp: "DISK_FULL"},
+	}
+	for _, tc := range cases {
+		out, err := tmpl.ExecuteTextString(tc.text, data)
+		assert.NoError(t, err, tc.text)
+		assert.Equal(t, tc.exp, out, tc.text)
+	}
+}
+
+func Test_humanizeDuration(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "0s", humanizeDuration(300*time.Millisecond))
+	assert.Equal(t, "45s", humanizeDuration(45*time.Second))
+	assert.Equal(t, "5m10s", humanizeDuration(5*time.Minute+10*time.Second))
+	assert.Equal(t, "2h", humanizeDuration(2*time.Hour+20*time.Second))
+	assert.Equal(t, "2h5m", humanizeDuration(2*time.Hour+5*time.Minute))
+	assert.Equal(t, "3d4h", humanizeDuration(76*time.Hour+30*time.Minute))
+	assert.Equal(t, "1m", humanizeDuration(-time.Minute))
+}
+
+func Test_receiverTemplates(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"global.tmpl": `{{ define "team" }}global{{ end }}`,
+		"ops.tmpl":    `{{ define "team" }}ops{{ end }}`,
+		"sms.tmpl":    `{{ define "sms" }}sms{{ end }}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := Config{
+		Templates:         []string{filepath.Join(dir, "global.tmpl")},
+		ProviderTemplates: map[string][]string{"twilio": {filepath.Join(dir, "sms.tmpl")}},
+		Receivers: []ReceiverConf{
+			{Name: "dev", Provider: "messagebird"},
+			{Name: "ops", Provider: "messagebird", Templates: []string{filepath.Join(dir, "ops.tmpl")}},
+			{Name: "oncall", Provider: "twilio"},
+			{Name: "chat", Provider: "telegram"},
+		},
+	}
+	tmpl, err := newTemplates(config.Templates...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverTmpls, err := config.receiverTemplates(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &loadedConfig{config: config, tmpl: tmpl, receiverTmpls: receiverTmpls}
+	assert.Len(t, receiverTmpls, 3)
+
+	data := template.Data{
+		Status:       "firing",
+		Alerts:       template.Alerts{{Status: "firing", Annotations: template.KV{"summary": "CPU_high"}}},
+		CommonLabels: template.KV{"alertname": "HighCPU"},
+	}
+	cases := []struct {
+		receiver string
+		text     string
+		exp      string
+		err      string
+	}{
+		{receiver: "dev", text: `{{ template "team" . }}`, exp: "global"},
+		{receiver: "ops", text: `{{ template "team" . }}`, exp: "ops"},
+		{receiver: "oncall", text: `{{ template "sms" . }}`, exp: "sms"},
+		{receiver: "dev", text: `{{ template "sms" . }}`, err: `template: :1:12: executing "" at <{{template "sms" .}}>: template "sms" not defined`},
+		{receiver: "dev", text: `{{ template "sachet.text" . }}`, exp: "[FIRING:1] HighCPU\n- CPU_high\n"},
+		{receiver: "chat", text: `{{ template "sachet.text" . }}`, exp: "*[FIRING:1] HighCPU*\n• CPU\\_high\n"},
+	}
+	for _, tc := range cases {
+		rc := config.receiverConfByReceiver(tc.receiver)
+		out, err := c.templatesFor(rc).ExecuteTextString(tc.text, data)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.receiver)
+			continue
+		}
+		assert.NoError(t, err, tc.receiver)
+		assert.Equal(t, tc.exp, out, tc.receiver)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/messagebird/sachet"
+)
+
+// contextProvider records the span of the context it is sent with.
+type contextProvider struct {
+	span *trace.SpanContext
+}
+
+func (p contextProvider) Send(sachet.Message) error { return nil }
+
+func (p contextProvider) SendContext(ctx context.Context, _ sachet.Message) error {
+	*p.span = trace.SpanContextFromContext(ctx)
+	return nil
+}
+
+// spanRecorder records the spans of the global tracer provider. The global
+// tracer of the handlers is bound to the first provider set, so it is set once.
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+func Test_Alert_tracing(t *testing.T) {
+	recorder := spanRecorder()
+
+	var sent trace.SpanContext
+	c, _ := newTestConfig(t, ReceiverConf{Name: "tracing", Provider: "traced", To: []string{"1"}})
+	c.providers["traced"] = contextProvider{span: &sent}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	body := `{"receiver": "tracing", "status": "firing", "alerts": [{"status": "firing"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handlers{}.Alert(w, r)
+	parent.End()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var names []string
+	var sendSpan trace.SpanContext
+	for _, span := range recorder.Ended() {
+		// Spans of earlier runs of this test are in other traces.
+		if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+			continue
+		}
+		names = append(names, span.Name())
+		if span.Name() == "send" {
+			sendSpan = span.SpanContext()
+		}
+	}
+	assert.Equal(t, []string{"decode", "route", "render", "send", "request"}, names)
+
+	// The provider makes its requests with the context of the send span.
+	assert.Equal(t, sendSpan.SpanID(), sent.SpanID())
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+
+	"github.com/messagebird/sachet"
+)
+
+// webConfig is the web configuration file, which secures the HTTP endpoints.
+// Its TLS section and basic_auth_users follow the format of the Prometheus
+// exporter-toolkit, so existing files can be reused.
+type webConfig struct {
+	TLSServerConfig *tlsServerConfig `yaml:"tls_server_config"`
+
+	// endpointAuth at the top level applies to every endpoint group that
+	// does not configure its own authentication.
+	endpointAuth `yaml:",inline"`
+
+	Endpoints struct {
+		// Alert covers the endpoints that send messages.
+		Alert endpointAuth `yaml:"alert"`
+		// Admin covers the endpoints that reload or expose the configuration
+		// and render templates.
+		Admin endpointAuth `yaml:"admin"`
+		// Metrics covers the Prometheus metrics endpoint.
+		Metrics endpointAuth `yaml:"metrics"`
+	} `yaml:"endpoints"`
+}
+
+type tlsServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type"`
+	ClientCAFile   string `yaml:"client_ca_file"`
+	MinVersion     string `yaml:"min_version"`
+}
+
+// endpointAuth is the authentication required by a group of endpoints. A
+// group without basic auth users or bearer tokens accepts any request that
+// passes the client certificate check.
+type endpointAuth struct {
+	// BasicAuthUsers maps user names to bcrypt hashes of their passwords.
+	BasicAuthUsers map[string]sachet.Secret `yaml:"basic_auth_users,omitempty"`
+	// BearerTokens are accepted in an "Authorization: Bearer" header.
+	BearerTokens []sachet.Secret `yaml:"bearer_tokens,omitempty"`
+	// RequireClientCert rejects requests without a client certificate
+	// verified against the client_ca_file of the TLS configuration.
+	RequireClientCert bool `yaml:"require_client_cert,omitempty"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// loadWebConfig reads and validates the web configuration file. An empty
+// filename returns a configuration serving plain HTTP without authentication.
+func loadWebConfig(filename string) (*webConfig, error) {
+	c := &webConfig{}
+	if filename == "" {
+		return c, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, err
+	}
+
+	if err := c.endpointAuth.validate(c.TLSServerConfig); err != nil {
+		return nil, err
+	}
+	for _, group := range []struct {
+		name string
+		auth endpointAuth
+	}{
+		{"alert", c.Endpoints.Alert},
+		{"admin", c.Endpoints.Admin},
+		{"metrics", c.Endpoints.Metrics},
+	} {
+		if err := group.auth.validate(c.TLSServerConfig); err != nil {
+			return nil, fmt.Errorf("endpoints.%s.%w", group.name, err)
+		}
+	}
+
+	if c.TLSServerConfig != nil {
+		if _, err := c.tlsConfig(); err != nil {
+			return nil, fmt.Errorf("tls_server_config: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// groups returns the authentication of every endpoint group, with the top
+// level one applied to the groups that have none.
+func (c *webConfig) groups() map[string]endpointAuth {
+	groups := map[string]endpointAuth{
+		"alert":   c.Endpoints.Alert,
+		"admin":   c.Endpoints.Admin,
+		"metrics": c.Endpoints.Metrics,
+	}
+	for name, auth := range groups {
+		if auth.isZero() {
+			groups[name] = c.endpointAuth
+		}
+	}
+	return groups
+}
+
+// protect wraps the handler of an endpoint in the group with that name.
+func (c *webConfig) protect(group string, h http.Handler) http.Handler {
+	auth := c.groups()[group]
+	if auth.isZero() {
+		return h
+	}
+	return auth.handler(h)
+}
+
+// tlsConfig returns the TLS configuration of the server. The certificate and
+// key are read on every handshake, so renewed certificates are used without
+// a restart.
+func (c *webConfig) tlsConfig() (*tls.Config, error) {
+	tc := c.TLSServerConfig
+	if tc.CertFile == "" || tc.KeyFile == "" {
+		return nil, errors.New("cert_file and key_file are required")
+	}
+	if _, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		},
+	}
+
+	if tc.MinVersion != "" {
+		version, ok := tlsVersions[tc.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown min_version %q", tc.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if tc.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(tc.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", tc.ClientCAFile)
+		}
+		// Endpoint groups decide whether they require a certificate.
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if tc.ClientAuthType != "" {
+		clientAuth, ok := clientAuthTypes[tc.ClientAuthType]
+		if !ok {
+			return nil, fmt.Errorf("unknown client_auth_type %q", tc.ClientAuthType)
+		}
+		if clientAuth >= tls.VerifyClientCertIfGiven && config.ClientCAs == nil {
+			return nil, fmt.Errorf("client_auth_type %s needs client_ca_file", tc.ClientAuthType)
+		}
+		config.ClientAuth = clientAuth
+	}
+
+	return config, nil
+}
+
+func (a endpointAuth) isZero() bool {
+	return len(a.BasicAuthUsers) == 0 && len(a.BearerTokens) == 0 && !a.RequireClientCert
+}
+
+func (a endpointAuth) validate(tc *tlsServerConfig) error {
+	if a.RequireClientCert && (tc == nil || tc.ClientCAFile == "") {
+		return errors.New("require_client_cert: needs tls_server_config.client_ca_file")
+	}
+	for user, hash := range a.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("basic_auth_users: %s: password must be a bcrypt hash: %w", user, err)
+		}
+	}
+	for _, token := range a.BearerTokens {
+		if token == "" {
+			return errors.New("bearer_tokens: empty token")
+		}
+	}
+	return nil
+}
+
+// handler wraps h so that only requests authenticated as a requires reach it.
+func (a endpointAuth) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.RequireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			writeError(w, r, http.StatusUnauthorized, errors.New("client certificate required"))
+			return
+		}
+
+		if (len(a.BasicAuthUsers) > 0 || len(a.BearerTokens) > 0) && !a.authenticated(r) {
+			if len(a.BasicAuthUsers) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="sachet", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeError(w, r, http.StatusUnauthorized, errors.New("authentication required"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// unknownUserHash is the bcrypt hash that passwords of unknown basic auth
+// users are compared against, so that they take as long to reject as wrong
+// passwords and user names cannot be guessed from the response time.
+const unknownUserHash = "$2a$10$DLvsWcMP1ua0UmjTmuJuGuFyxIyid1.i7X74kAJWy6WPc7QFk5Oti"
+
+// authenticated reports whether r carries valid basic auth credentials or a
+// valid bearer token.
+func (a endpointAuth) authenticated(r *http.Request) bool {
+	if user, password, ok := r.BasicAuth(); ok {
+		hash, known := a.BasicAuthUsers[user]
+		if !known {
+			hash = unknownUserHash
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil && known
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(header, "Bearer "))
+	for _, t := range a.BearerTokens {
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_webConfig_protect(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "web.yaml")
+	content := `
+basic_auth_users:
+  alice: '` + string(hash) + `'
+endpoints:
+  alert:
+    bearer_tokens: ['s3cr3t']
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	web, err := loadWebConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cases := []struct {
+		name   string
+		group  string
+		setup  func(r *http.Request)
+		status int
+	}{
+		{
+			name:   "alert without token",
+			group:  "alert",
+			setup:  func(r *http.Request) {},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "alert with token",
+			group:  "alert",
+			setup:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cr3t") },
+			status: http.StatusOK,
+		},
+		{
+			name:   "alert with wrong token",
+			group:  "alert",
+			setup:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "alert does not inherit basic auth",
+			group:  "alert",
+			setup:  func(r *http.Request) { r.SetBasicAuth("alice", "hunter2") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "admin with basic auth",
+			group:  "admin",
+			setup:  func(r *http.Request) { r.SetBasicAuth("alice", "hunter2") },
+			status: http.StatusOK,
+		},
+		{
+			name:   "admin with wrong password",
+			group:  "admin",
+			setup:  func(r *http.Request) { r.SetBasicAuth("alice", "guess") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "metrics with unknown user",
+			group:  "metrics",
+			setup:  func(r *http.Request) { r.SetBasicAuth("bob", "hunter2") },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "metrics with unknown user and the password of the unknown user hash",
+			group:  "metrics",
+			setup:  func(r *http.Request) { r.SetBasicAuth("bob", "sachet-unknown-user") },
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		tc.setup(r)
+		web.protect(tc.group, ok).ServeHTTP(w, r)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+	}
+}
+
+func Test_unknownUserHash(t *testing.T) {
+	t.Parallel()
+
+	// Unknown users are only as slow to reject as known ones if the hash is
+	// valid and as costly as the hashes users generate by default.
+	cost, err := bcrypt.Cost([]byte(unknownUserHash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
+
+func Test_loadWebConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "plain password",
+			content: "basic_auth_users:\n  alice: hunter2\n",
+			err:     "basic_auth_users: alice: password must be a bcrypt hash: crypto/bcrypt: hashedSecret too short to be a bcrypted password",
+		},
+		{
+			name:    "client certificate without CA",
+			content: "endpoints:\n  alert:\n    require_client_cert: true\n",
+			err:     "endpoints.alert.require_client_cert: needs tls_server_config.client_ca_file",
+		},
+		{
+			name:    "TLS without key",
+			content: "tls_server_config:\n  cert_file: server.crt\n",
+			err:     "tls_server_config: cert_file and key_file are required",
+		},
+	}
+	for _, tc := range cases {
+		filename := filepath.Join(t.TempDir(), "web.yaml")
+		if err := ioutil.WriteFile(filename, []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadWebConfig(filename)
+		assert.EqualError(t, err, tc.err, tc.name)
+	}
+}
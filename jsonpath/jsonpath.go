@@ -0,0 +1,196 @@
+// Package jsonpath evaluates the subset of JSONPath used to pick values out
+// of webhook payloads and gateway responses: member access with .name or
+// ['name'], array indexes with [n], negative ones counting from the end, and
+// the [*] and .* wildcards. Documents are JSON decoded into interface{}.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type stepKind int
+
+const (
+	member stepKind = iota
+	index
+	wildcard
+)
+
+type step struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses a JSONPath expression, which starts with $ for the root of
+// the document.
+func Compile(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with $", expr)
+	}
+
+	p := &Path{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		var (
+			s   step
+			err error
+		)
+		switch rest[0] {
+		case '.':
+			s, rest, err = parseMember(rest[1:])
+		case '[':
+			s, rest, err = parseBracket(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jsonpath %q: %w", expr, err)
+		}
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+// MustCompile is like Compile but panics if the expression is invalid.
+func MustCompile(expr string) *Path {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func parseMember(s string) (step, string, error) {
+	if strings.HasPrefix(s, "*") {
+		return step{kind: wildcard}, s[1:], nil
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return step{}, "", fmt.Errorf("member name missing")
+	}
+	return step{kind: member, name: s[:end]}, s[end:], nil
+}
+
+func parseBracket(s string) (step, string, error) {
+	if strings.HasPrefix(s, "*]") {
+		return step{kind: wildcard}, s[2:], nil
+	}
+	if s != "" && (s[0] == '\'' || s[0] == '"') {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 || !strings.HasPrefix(s[end+2:], "]") {
+			return step{}, "", fmt.Errorf("unterminated member name")
+		}
+		return step{kind: member, name: s[1 : end+1]}, s[end+3:], nil
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return step{}, "", fmt.Errorf("missing ]")
+	}
+	i, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return step{}, "", fmt.Errorf("invalid index %q", s[:end])
+	}
+	return step{kind: index, index: i}, s[end+1:], nil
+}
+
+// String returns the expression p was compiled from.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns the values p selects in doc, in document order. Members of
+// objects are visited in the order of their names for wildcards.
+func (p *Path) Find(doc interface{}) []interface{} {
+	values := []interface{}{doc}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, v := range values {
+			next = s.apply(v, next)
+		}
+		values = next
+	}
+	return values
+}
+
+// FindString returns the first value p selects in doc formatted with
+// String, and whether there was one.
+func (p *Path) FindString(doc interface{}) (string, bool) {
+	values := p.Find(doc)
+	if len(values) == 0 {
+		return "", false
+	}
+	return String(values[0]), true
+}
+
+func (s step) apply(v interface{}, out []interface{}) []interface{} {
+	switch s.kind {
+	case member:
+		if obj, ok := v.(map[string]interface{}); ok {
+			if child, ok := obj[s.name]; ok {
+				out = append(out, child)
+			}
+		}
+	case index:
+		if arr, ok := v.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				out = append(out, arr[i])
+			}
+		}
+	case wildcard:
+		switch v := v.(type) {
+		case []interface{}:
+			out = append(out, v...)
+		case map[string]interface{}:
+			names := make([]string, 0, len(v))
+			for name := range v {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				out = append(out, v[name])
+			}
+		}
+	}
+	return out
+}
+
+// String formats a JSON value as text: strings as they are, numbers without
+// exponent, null as the empty string, and objects and arrays as JSON.
+func String(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,78 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"status": "ok",
+		"count": 2,
+		"ratio": 0.5,
+		"messages": [
+			{"id": "a1", "to": "+31612345678", "status": {"code": 0}},
+			{"id": "b2", "to": "+31687654321", "status": {"code": 3}}
+		],
+		"tags": {"env": "prod", "app": "web"},
+		"odd key": true,
+		"empty": null
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		expr string
+		exp  []string
+	}{
+		{expr: "$", exp: []string{`{"count":2,"empty":null,"messages":[{"id":"a1","status":{"code":0},"to":"+31612345678"},{"id":"b2","status":{"code":3},"to":"+31687654321"}],"odd key":true,"ratio":0.5,"status":"ok","tags":{"app":"web","env":"prod"}}`}},
+		{expr: "$.status", exp: []string{"ok"}},
+		{expr: "$.count", exp: []string{"2"}},
+		{expr: "$.ratio", exp: []string{"0.5"}},
+		{expr: "$['odd key']", exp: []string{"true"}},
+		{expr: `$["tags"].env`, exp: []string{"prod"}},
+		{expr: "$.empty", exp: []string{""}},
+		{expr: "$.messages[0].id", exp: []string{"a1"}},
+		{expr: "$.messages[-1].status.code", exp: []string{"3"}},
+		{expr: "$.messages[*].id", exp: []string{"a1", "b2"}},
+		{expr: "$.messages.*.to", exp: []string{"+31612345678", "+31687654321"}},
+		{expr: "$.tags.*", exp: []string{"web", "prod"}},
+		{expr: "$.messages[2].id", exp: []string{}},
+		{expr: "$.missing.id", exp: []string{}},
+		{expr: "$.status[0]", exp: []string{}},
+	}
+	for _, tc := range cases {
+		values := MustCompile(tc.expr).Find(doc)
+		got := []string{}
+		for _, v := range values {
+			got = append(got, String(v))
+		}
+		assert.Equal(t, tc.exp, got, tc.expr)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "status", err: `jsonpath "status": must start with $`},
+		{expr: "$.", err: `jsonpath "$.": member name missing`},
+		{expr: "$.a[", err: `jsonpath "$.a[": missing ]`},
+		{expr: "$.a[x]", err: `jsonpath "$.a[x]": invalid index "x"`},
+		{expr: "$['a", err: `jsonpath "$['a": unterminated member name`},
+		{expr: "$a", err: `jsonpath "$a": unexpected 'a'`},
+	}
+	for _, tc := range cases {
+		_, err := Compile(tc.expr)
+		assert.EqualError(t, err, tc.err, tc.expr)
+	}
+}
@@ -0,0 +1,142 @@
+// Package phonenumber validates phone numbers and normalises them into the
+// format expected by an SMS provider.
+package phonenumber
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Format is the way a provider expects recipient phone numbers to be written.
+type Format int
+
+const (
+	// E164 writes numbers with a leading plus, e.g. +31612345678.
+	E164 Format = iota + 1
+	// E164WithoutPlus writes numbers in E.164 without the leading plus, e.g. 31612345678.
+	E164WithoutPlus
+	// National writes numbers as dialled inside their own country, without spaces, e.g. 0612345678.
+	National
+)
+
+func (f Format) String() string {
+	switch f {
+	case E164:
+		return "e164"
+	case E164WithoutPlus:
+		return "e164_without_plus"
+	case National:
+		return "national"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ErrNoDefaultCountry is the cause of the errors of numbers written without
+// an international prefix when no default country is given.
+var ErrNoDefaultCountry = errors.New("no international prefix and no default country configured")
+
+// Error describes why a phone number was rejected.
+type Error struct {
+	Number string
+	Reason string
+	// Err is the cause of the error, if it is one callers can test for.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid phone number %q: %s", e.Number, e.Reason)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Number is a validated phone number.
+type Number struct {
+	number *phonenumbers.PhoneNumber
+}
+
+// Parse validates number and returns it as a Number. Numbers without an
+// international prefix are interpreted as belonging to defaultCountry, an
+// ISO 3166-1 alpha-2 code such as "NL" or "IR".
+func Parse(number, defaultCountry string) (Number, error) {
+	defaultCountry = strings.ToUpper(strings.TrimSpace(defaultCountry))
+	if defaultCountry != "" && phonenumbers.GetCountryCodeForRegion(defaultCountry) == 0 {
+		return Number{}, &Error{Number: number, Reason: fmt.Sprintf("unknown default country %q", defaultCountry)}
+	}
+
+	parsed, err := phonenumbers.Parse(number, defaultCountry)
+	if errors.Is(err, phonenumbers.ErrInvalidCountryCode) && defaultCountry == "" {
+		return Number{}, &Error{Number: number, Reason: ErrNoDefaultCountry.Error(), Err: ErrNoDefaultCountry}
+	}
+	if err != nil {
+		return Number{}, &Error{Number: number, Reason: parseErrorReason(err)}
+	}
+
+	switch phonenumbers.IsPossibleNumberWithReason(parsed) {
+	case phonenumbers.INVALID_COUNTRY_CODE:
+		return Number{}, &Error{Number: number, Reason: "unknown country calling code"}
+	case phonenumbers.TOO_SHORT:
+		return Number{}, &Error{Number: number, Reason: "too short"}
+	case phonenumbers.TOO_LONG:
+		return Number{}, &Error{Number: number, Reason: "too long"}
+	case phonenumbers.INVALID_LENGTH:
+		return Number{}, &Error{Number: number, Reason: "wrong number of digits"}
+	}
+
+	if !phonenumbers.IsValidNumber(parsed) {
+		region := phonenumbers.GetRegionCodeForCountryCode(int(parsed.GetCountryCode()))
+		return Number{}, &Error{Number: number, Reason: "not a valid number for country " + region}
+	}
+
+	return Number{number: parsed}, nil
+}
+
+func parseErrorReason(err error) string {
+	switch {
+	case errors.Is(err, phonenumbers.ErrInvalidCountryCode):
+		return "unknown country calling code"
+	case errors.Is(err, phonenumbers.ErrNotANumber):
+		return "not a number"
+	case errors.Is(err, phonenumbers.ErrTooShortNSN), errors.Is(err, phonenumbers.ErrTooShortAfterIDD):
+		return "too short"
+	case errors.Is(err, phonenumbers.ErrNumTooLong):
+		return "too long"
+	}
+	return err.Error()
+}
+
+// Format returns the number written in the given format.
+func (n Number) Format(f Format) string {
+	switch f {
+	case E164WithoutPlus:
+		return strings.TrimPrefix(phonenumbers.Format(n.number, phonenumbers.E164), "+")
+	case National:
+		national := phonenumbers.Format(n.number, phonenumbers.NATIONAL)
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, national)
+	}
+	return phonenumbers.Format(n.number, phonenumbers.E164)
+}
+
+// String returns the number in E.164 format.
+func (n Number) String() string {
+	return n.Format(E164)
+}
+
+// Normalize validates number and returns it written in format f.
+func Normalize(number, defaultCountry string, f Format) (string, error) {
+	n, err := Parse(number, defaultCountry)
+	if err != nil {
+		return "", err
+	}
+	return n.Format(f), nil
+}
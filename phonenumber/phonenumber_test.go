@@ -0,0 +1,100 @@
+package phonenumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		number  string
+		country string
+		format  Format
+		exp     string
+		err     string
+	}{
+		{
+			name:   "e164",
+			number: "+31 6 12345678",
+			format: E164,
+			exp:    "+31612345678",
+		},
+		{
+			name:    "national with default country",
+			number:  "06-12345678",
+			country: "nl",
+			format:  E164,
+			exp:     "+31612345678",
+		},
+		{
+			name:   "e164 without plus",
+			number: "+31612345678",
+			format: E164WithoutPlus,
+			exp:    "31612345678",
+		},
+		{
+			name:    "international to national",
+			number:  "+98 912 345 6789",
+			country: "IR",
+			format:  National,
+			exp:     "09123456789",
+		},
+		{
+			name:    "international dialling prefix",
+			number:  "0031612345678",
+			country: "NL",
+			format:  E164,
+			exp:     "+31612345678",
+		},
+		{
+			name:   "no default country",
+			number: "09123456789",
+			format: E164,
+			err:    `invalid phone number "09123456789": no international prefix and no default country configured`,
+		},
+		{
+			name:    "incomplete",
+			number:  "09123 456",
+			country: "IR",
+			format:  National,
+			err:     `invalid phone number "09123 456": not a valid number for country IR`,
+		},
+		{
+			name:   "too short",
+			number: "+31 6",
+			format: E164,
+			err:    `invalid phone number "+31 6": too short`,
+		},
+		{
+			name:    "unknown default country",
+			number:  "0612345678",
+			country: "XX",
+			format:  E164,
+			err:     `invalid phone number "0612345678": unknown default country "XX"`,
+		},
+		{
+			name:   "not a number",
+			number: "device:My Nickname",
+			format: E164,
+			err:    `invalid phone number "device:My Nickname": not a number`,
+		},
+	}
+	for _, tc := range cases {
+		got, err := Normalize(tc.number, tc.country, tc.format)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.exp, got, tc.name)
+	}
+
+	_, err := Normalize("09123456789", "", E164)
+	assert.ErrorIs(t, err, ErrNoDefaultCountry)
+	_, err = Normalize("09123 456", "IR", E164)
+	assert.NotErrorIs(t, err, ErrNoDefaultCountry)
+}
@@ -11,31 +11,44 @@ import (
 )
 
 type Config struct {
-	RegionId        string `yaml:"region_id"`
-	AccessKey       string `yaml:"access_key"`
-	AccessKeySecret string `yaml:"access_key_secret"`
+	RegionId            string        `yaml:"region_id"`
+	AccessKey           string        `yaml:"access_key"`
+	AccessKeySecret     sachet.Secret `yaml:"access_key_secret"`
+	AccessKeySecretFile string        `yaml:"access_key_secret_file"`
 
 	SignName         string `yaml:"sign_name"`
 	TemplateCode     string `yaml:"template_code"`
 	TemplateParamKey string `yaml:"template_param_key"`
 }
 
-var _ (sachet.Provider) = (*Aliyun)(nil)
+var _ (sachet.CapableProvider) = (*Aliyun)(nil)
 
 type Aliyun struct {
-	client *dysmsapi.Client
 	config *Config
 }
 
 func NewAliyun(config Config) (*Aliyun, error) {
-	client, err := dysmsapi.NewClientWithAccessKey(config.RegionId, config.AccessKey, config.AccessKeySecret)
-	if err != nil {
+	aliyun := &Aliyun{config: &config}
+	if _, err := aliyun.newClient(); err != nil {
 		return nil, err
 	}
-	return &Aliyun{
-		client: client,
-		config: &config,
-	}, nil
+	return aliyun, nil
+}
+
+// newClient creates a client for a single send. Clients of the Alibaba Cloud
+// SDK set the timeouts of their HTTP client on every request, so they cannot
+// be shared by concurrent sends.
+func (aliyun *Aliyun) newClient() (*dysmsapi.Client, error) {
+	return dysmsapi.NewClientWithAccessKey(aliyun.config.RegionId, aliyun.config.AccessKey, string(aliyun.config.AccessKeySecret))
+}
+
+// Capabilities returns what the Aliyun provider supports.
+// The sender is configured with sign_name instead of Message.From.
+func (aliyun *Aliyun) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text"},
+		Batch:        true,
+	}
 }
 
 func (aliyun *Aliyun) Send(message sachet.Message) error {
@@ -51,8 +64,12 @@ func (aliyun *Aliyun) Send(message sachet.Message) error {
 		templateParamByte, err := json.Marshal(templateParam)
 		if err == nil {
 			request.TemplateParam = string(templateParamByte)
+			var client *dysmsapi.Client
+			if client, err = aliyun.newClient(); err != nil {
+				return err
+			}
 			var response *dysmsapi.SendSmsResponse
-			response, err = aliyun.client.SendSms(request)
+			response, err = client.SendSms(request)
 			if err == nil && (!response.IsSuccess() || response.Code != "OK") {
 				return fmt.Errorf(response.String())
 			}
@@ -2,6 +2,7 @@ package aspsms
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -13,11 +14,14 @@ import (
 
 // Config is the configuration struct for AspSms provider.
 type Config struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Username     string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
 }
 
 var _ (sachet.Provider) = (*AspSms)(nil)
+var _ (sachet.ContextProvider) = (*AspSms)(nil)
+var _ (sachet.HTTPProvider) = (*AspSms)(nil)
 
 // AspSms contains the necessary values for the AspSms provider.
 type AspSms struct {
@@ -34,6 +38,11 @@ func NewAspSms(config Config) *AspSms {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to ASPSMS.
+func (c *AspSms) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.httpClient.Transport = wrap(sachet.TransportOrDefault(c.httpClient.Transport))
+}
+
 type requestPayload struct {
 	Username    string   `json:"UserName"`
 	Password    string   `json:"Password"`
@@ -46,9 +55,14 @@ const apiUrl = "https://json.aspsms.com/SendSimpleTextSMS"
 
 // Send sends SMS to user registered in configuration.
 func (c *AspSms) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *AspSms) SendContext(ctx context.Context, message sachet.Message) error {
 	params := requestPayload{
 		Username:    c.Username,
-		Password:    c.Password,
+		Password:    string(c.Password),
 		Originator:  message.From,
 		Recipients:  message.To,
 		MessageText: message.Text,
@@ -59,7 +73,7 @@ func (c *AspSms) Send(message sachet.Message) error {
 		return err
 	}
 
-	request, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
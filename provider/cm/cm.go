@@ -2,6 +2,7 @@ package cm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,21 +13,31 @@ import (
 
 // Config is the configuration struct for CM provider.
 type Config struct {
-	ProductToken string `yaml:"producttoken"`
+	ProductToken     sachet.Secret `yaml:"producttoken"`
+	ProductTokenFile string        `yaml:"producttoken_file"`
 }
 
 var _ (sachet.Provider) = (*CM)(nil)
+var _ (sachet.ContextProvider) = (*CM)(nil)
+var _ (sachet.HTTPProvider) = (*CM)(nil)
 
 // CM contains the necessary values for the CM provider.
 type CM struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
-var cmHTTPClient = &http.Client{Timeout: time.Second * 20}
-
 // NewCM creates and returns a new CM struct.
 func NewCM(config Config) *CM {
-	return &CM{config}
+	return &CM{
+		config,
+		&http.Client{Timeout: time.Second * 20},
+	}
+}
+
+// WrapTransport wraps the transport of the requests to CM.
+func (c *CM) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
 }
 
 type CMRecipient struct {
@@ -52,10 +63,15 @@ type CMPayload struct {
 
 // Send sends SMS to n number of people using Bulk SMS API.
 func (c *CM) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *CM) SendContext(ctx context.Context, message sachet.Message) error {
 	smsURL := "https://gw.cmtelecom.com/v1.0/message"
 
 	payload := CMPayload{}
-	payload.Messages.Authentication.ProductToken = c.Config.ProductToken
+	payload.Messages.Authentication.ProductToken = string(c.Config.ProductToken)
 	payload.Messages.MSG = append(payload.Messages.MSG, CMMessage{})
 
 	payload.Messages.MSG[0].From = message.From
@@ -75,14 +91,14 @@ func (c *CM) Send(message sachet.Message) error {
 		return err
 	}
 
-	request, err := http.NewRequest("POST", smsURL, bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(ctx, "POST", smsURL, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("User-Agent", "Sachet")
 
-	response, err := cmHTTPClient.Do(request)
+	response, err := c.HTTPClient.Do(request)
 	if err != nil {
 		return err
 	}
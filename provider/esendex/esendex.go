@@ -4,6 +4,7 @@ package esendex
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -19,12 +20,15 @@ const (
 )
 
 type Config struct {
-	User             string `yaml:"user"`
-	ApiToken         string `yaml:"api_token"`
-	AccountReference string `yaml:"account_reference"`
+	User             string        `yaml:"user"`
+	ApiToken         sachet.Secret `yaml:"api_token"`
+	ApiTokenFile     string        `yaml:"api_token_file"`
+	AccountReference string        `yaml:"account_reference"`
 }
 
 var _ (sachet.Provider) = (*Esendex)(nil)
+var _ (sachet.ContextProvider) = (*Esendex)(nil)
+var _ (sachet.HTTPProvider) = (*Esendex)(nil)
 
 type Esendex struct {
 	Config
@@ -38,9 +42,19 @@ func NewEsendex(config Config) *Esendex {
 	}
 }
 
-func (e *Esendex) Send(message sachet.Message) (err error) {
+// WrapTransport wraps the transport of the requests to Esendex.
+func (e *Esendex) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	e.httpClient.Transport = wrap(sachet.TransportOrDefault(e.httpClient.Transport))
+}
+
+func (e *Esendex) Send(message sachet.Message) error {
+	return e.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (e *Esendex) SendContext(ctx context.Context, message sachet.Message) (err error) {
 	for _, phoneNumber := range message.To {
-		err = e.sendOne(message, phoneNumber)
+		err = e.sendOne(ctx, message, phoneNumber)
 
 		if err != nil {
 			return fmt.Errorf("failed to make API call to Esendex: %w", err)
@@ -71,7 +85,7 @@ type requestMessage struct {
 	Body string `json:"body"`
 }
 
-func (e *Esendex) sendOne(message sachet.Message, phoneNumber string) (err error) {
+func (e *Esendex) sendOne(ctx context.Context, message sachet.Message, phoneNumber string) (err error) {
 	params := requestPayload{
 		AccountReference: e.AccountReference,
 		Messages: []requestMessage{
@@ -89,7 +103,7 @@ func (e *Esendex) sendOne(message sachet.Message, phoneNumber string) (err error
 	}
 
 	var request *http.Request
-	request, err = http.NewRequest("POST", endpoint, bytes.NewBuffer(data))
+	request, err = http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(data))
 
 	if err != nil {
 		return
@@ -97,7 +111,7 @@ func (e *Esendex) sendOne(message sachet.Message, phoneNumber string) (err error
 
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("User-Agent", "Sachet")
-	request.SetBasicAuth(e.Config.User, e.Config.ApiToken)
+	request.SetBasicAuth(e.Config.User, string(e.Config.ApiToken))
 
 	response, err := e.httpClient.Do(request)
 	if err != nil {
@@ -1,6 +1,7 @@
 package exotel
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,29 +13,47 @@ import (
 
 // Config configuration struct for exotel Client.
 type Config struct {
-	AccountSID string `yaml:"account_sid"`
-	AuthToken  string `yaml:"auth_token"`
+	AccountSID    string        `yaml:"account_sid"`
+	AuthToken     sachet.Secret `yaml:"auth_token"`
+	AuthTokenFile string        `yaml:"auth_token_file"`
 }
 
 // ExotelRequestTimeout  is the timeout for http request to exotel.
 const ExotelRequestTimeout = time.Second * 20
 
 var _ (sachet.Provider) = (*Exotel)(nil)
+var _ (sachet.ContextProvider) = (*Exotel)(nil)
+var _ (sachet.HTTPProvider) = (*Exotel)(nil)
 
 // Exotel is the exte Exotel.
 type Exotel struct {
 	AccountSid string
 	Token      string
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 // NewExotel creates a new.
 func NewExotel(config Config) *Exotel {
-	Exotel := &Exotel{AccountSid: config.AccountSID, Token: config.AuthToken}
+	Exotel := &Exotel{
+		AccountSid: config.AccountSID,
+		Token:      string(config.AuthToken),
+		HTTPClient: &http.Client{Timeout: ExotelRequestTimeout},
+	}
 	return Exotel
 }
 
+// WrapTransport wraps the transport of the requests to Exotel.
+func (c *Exotel) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
 // Send send sms to n number of people using bulk sms api.
-func (c *Exotel) Send(message sachet.Message) (err error) {
+func (c *Exotel) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Exotel) SendContext(ctx context.Context, message sachet.Message) (err error) {
 	smsURL := fmt.Sprintf("https://twilix.exotel.in/v1/Accounts/%s/Sms/send.json", c.AccountSid)
 	var request *http.Request
 	var resp *http.Response
@@ -42,7 +61,7 @@ func (c *Exotel) Send(message sachet.Message) (err error) {
 	form := url.Values{"From": {message.From}, "Body": {message.Text}, "To": message.To}
 
 	// preparing the request.
-	request, err = http.NewRequest("POST", smsURL, strings.NewReader(form.Encode()))
+	request, err = http.NewRequestWithContext(ctx, "POST", smsURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return
 	}
@@ -52,10 +71,7 @@ func (c *Exotel) Send(message sachet.Message) (err error) {
 	request.Header.Set("User-Agent", "SachetV1.0")
 
 	// calling the endpoint.
-	httpClient := &http.Client{}
-	httpClient.Timeout = ExotelRequestTimeout
-
-	resp, err = httpClient.Do(request)
+	resp, err = c.HTTPClient.Do(request)
 	if err != nil {
 		return
 	}
@@ -2,6 +2,7 @@ package freemobile
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,26 +13,36 @@ import (
 
 // Config is the configuration struct for FreeMobile provider.
 type Config struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	URL      string `yaml:"url"`
+	Username     string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	URL          string        `yaml:"url"`
 }
 
 var _ (sachet.Provider) = (*FreeMobile)(nil)
+var _ (sachet.ContextProvider) = (*FreeMobile)(nil)
+var _ (sachet.HTTPProvider) = (*FreeMobile)(nil)
 
 // FreeMobile contains the necessary values for the FreeMobile provider.
 type FreeMobile struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
-var freemobileHTTPClient = &http.Client{Timeout: time.Second * 20}
-
 // NewFreeMobile creates and returns a new FreeMobile struct.
 func NewFreeMobile(config Config) *FreeMobile {
 	if config.URL == "" {
 		config.URL = "https://smsapi.free-mobile.fr/sendmsg"
 	}
-	return &FreeMobile{config}
+	return &FreeMobile{
+		config,
+		&http.Client{Timeout: time.Second * 20},
+	}
+}
+
+// WrapTransport wraps the transport of the requests to Free Mobile.
+func (c *FreeMobile) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
 }
 
 type payload struct {
@@ -42,9 +53,14 @@ type payload struct {
 
 // Send sends SMS to user registered in configuration.
 func (c *FreeMobile) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *FreeMobile) SendContext(ctx context.Context, message sachet.Message) error {
 	params := payload{
 		User:    c.Username,
-		Pass:    c.Password,
+		Pass:    string(c.Password),
 		Message: message.Text,
 	}
 
@@ -53,14 +69,14 @@ func (c *FreeMobile) Send(message sachet.Message) error {
 		return err
 	}
 
-	request, err := http.NewRequest("POST", c.URL, bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("User-Agent", "Sachet")
 
-	response, err := freemobileHTTPClient.Do(request)
+	response, err := c.HTTPClient.Do(request)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,559 @@
+// Package generichttp sends messages through gateways with a simple REST API,
+// described entirely in the configuration: the request is built from
+// templates and the response is checked with JSONPath expressions.
+package generichttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/jsonpath"
+	"github.com/messagebird/sachet/phonenumber"
+)
+
+// Config is the configuration of the generic HTTP provider.
+type Config struct {
+	// URL is a template, so that recipients can be part of the path. Values
+	// are escaped as path segments, query parameters belong in Params.
+	URL string `yaml:"url"`
+	// Method defaults to GET for the query encoding and to POST otherwise.
+	Method string `yaml:"method"`
+	// Headers are sent with every request, their values are templates.
+	// They are not secrets: credentials belong in Auth, which redacts them
+	// and reads them from files and the environment.
+	Headers map[string]string `yaml:"headers"`
+	Auth    AuthConfig        `yaml:"auth"`
+	// Encoding is json (the default), form or query. The json encoding sends
+	// the Body template, the others send Params.
+	Encoding string `yaml:"encoding"`
+	Body     string `yaml:"body"`
+	// Params are form or query parameters, their values are templates.
+	Params map[string]string `yaml:"params"`
+	// Batch sends one request for all recipients instead of one per
+	// recipient, with .To holding them joined by RecipientSeparator.
+	Batch              bool          `yaml:"batch"`
+	RecipientSeparator string        `yaml:"recipient_separator"`
+	Success            SuccessConfig `yaml:"success"`
+	// MessageIDPath selects the IDs of the sent messages in the response. A
+	// batch request gives each recipient its own ID if it matches one per
+	// recipient, and none otherwise as they cannot be told apart.
+	MessageIDPath string `yaml:"message_id_path"`
+	// MessageTypes lists the receiver types passed on as .Type.
+	MessageTypes  []string `yaml:"message_types"`
+	MaxTextLength int      `yaml:"max_text_length"`
+	// PhoneNumberFormat is e164, e164_without_plus or national. Recipients
+	// are not treated as phone numbers if it is empty.
+	PhoneNumberFormat string        `yaml:"phone_number_format"`
+	Timeout           time.Duration `yaml:"timeout"`
+}
+
+// AuthConfig is the way requests authenticate to the gateway.
+type AuthConfig struct {
+	// Type is basic, bearer, header or query.
+	Type         string        `yaml:"type"`
+	Username     string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	Token        sachet.Secret `yaml:"token"`
+	TokenFile    string        `yaml:"token_file"`
+	// Name is the header or query parameter carrying the token.
+	Name string `yaml:"name"`
+}
+
+// SuccessConfig tells successful responses apart from failed ones.
+type SuccessConfig struct {
+	// StatusCodes defaults to every 2xx status.
+	StatusCodes []int `yaml:"status_codes"`
+	// Path must select one of Values in the JSON response, or a value other
+	// than null, false, 0 and "" if Values is empty.
+	Path   string   `yaml:"path"`
+	Values []string `yaml:"values"`
+	// ErrorPath selects the reason of a failure in the response.
+	ErrorPath string `yaml:"error_path"`
+}
+
+var _ (sachet.CapableProvider) = (*GenericHTTP)(nil)
+var _ (sachet.ContextProvider) = (*GenericHTTP)(nil)
+var _ (sachet.ResultProvider) = (*GenericHTTP)(nil)
+var _ (sachet.PhoneNumberProvider) = (*phoneNumberGenericHTTP)(nil)
+var _ (sachet.HTTPProvider) = (*GenericHTTP)(nil)
+
+// GenericHTTP sends messages with requests built from its configuration.
+type GenericHTTP struct {
+	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
+
+	url           *template.Template
+	body          *template.Template
+	headers       map[string]*template.Template
+	params        map[string]*template.Template
+	successPath   *jsonpath.Path
+	errorPath     *jsonpath.Path
+	messageIDPath *jsonpath.Path
+}
+
+// phoneNumberGenericHTTP is a GenericHTTP addressing recipients by phone
+// number, so that they are validated and normalised when the configuration
+// is loaded.
+type phoneNumberGenericHTTP struct {
+	*GenericHTTP
+	format phonenumber.Format
+}
+
+// PhoneNumberFormat returns the format set in phone_number_format.
+func (p *phoneNumberGenericHTTP) PhoneNumberFormat() phonenumber.Format {
+	return p.format
+}
+
+// responseLimit is the size of the responses read from the gateway.
+const responseLimit = 1 << 20
+
+// templateFuncs are the functions of the request templates besides the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+}
+
+// NewGenericHTTP checks config and returns a provider sending with it.
+func NewGenericHTTP(config Config) (sachet.Provider, error) {
+	if config.URL == "" {
+		return nil, errors.New("url missing")
+	}
+	switch config.Encoding {
+	case "":
+		config.Encoding = "json"
+	case "json", "form", "query":
+	default:
+		return nil, fmt.Errorf("encoding: unknown encoding %q, expected one of: json, form, query", config.Encoding)
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+		if config.Encoding == "query" {
+			config.Method = http.MethodGet
+		}
+	}
+	if config.RecipientSeparator == "" {
+		config.RecipientSeparator = ","
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 20 * time.Second
+	}
+	if err := config.Auth.validate(); err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+
+	p := &GenericHTTP{
+		Config:     config,
+		HTTPClient: &http.Client{Timeout: config.Timeout},
+		headers:    map[string]*template.Template{},
+		params:     map[string]*template.Template{},
+	}
+
+	var err error
+	if p.url, err = parseTemplate("url", config.URL); err != nil {
+		return nil, err
+	}
+	switch {
+	case config.Encoding == "json" && config.Body == "":
+		return nil, errors.New("body missing for the json encoding")
+	case config.Encoding != "json" && config.Body != "":
+		return nil, fmt.Errorf("body: not sent with the %s encoding, use params", config.Encoding)
+	case config.Encoding == "json" && len(config.Params) > 0:
+		return nil, errors.New("params: not sent with the json encoding, use body")
+	case config.Encoding == "form" && len(config.Params) == 0:
+		return nil, errors.New("params missing for the form encoding")
+	}
+	if config.Body != "" {
+		if p.body, err = parseTemplate("body", config.Body); err != nil {
+			return nil, err
+		}
+	}
+	for name, text := range config.Headers {
+		if p.headers[name], err = parseTemplate("headers."+name, text); err != nil {
+			return nil, err
+		}
+		if credentialHeader(name) {
+			slog.Warn("Header values are not secrets and are shown in the configuration, set credentials with auth instead", "provider", "http", "header", name)
+		}
+	}
+	for name, text := range config.Params {
+		if p.params[name], err = parseTemplate("params."+name, text); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.successPath, err = compilePath(config.Success.Path); err != nil {
+		return nil, fmt.Errorf("success.path: %w", err)
+	}
+	if len(config.Success.Values) > 0 && p.successPath == nil {
+		return nil, errors.New("success.values: requires success.path")
+	}
+	if p.errorPath, err = compilePath(config.Success.ErrorPath); err != nil {
+		return nil, fmt.Errorf("success.error_path: %w", err)
+	}
+	if p.messageIDPath, err = compilePath(config.MessageIDPath); err != nil {
+		return nil, fmt.Errorf("message_id_path: %w", err)
+	}
+
+	if config.PhoneNumberFormat == "" {
+		return p, nil
+	}
+	for _, f := range []phonenumber.Format{phonenumber.E164, phonenumber.E164WithoutPlus, phonenumber.National} {
+		if config.PhoneNumberFormat == f.String() {
+			return &phoneNumberGenericHTTP{p, f}, nil
+		}
+	}
+	return nil, fmt.Errorf("phone_number_format: unknown format %q, expected one of: e164, e164_without_plus, national", config.PhoneNumberFormat)
+}
+
+// WrapTransport wraps the transport of the requests to the gateway.
+func (p *GenericHTTP) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	p.HTTPClient.Transport = wrap(sachet.TransportOrDefault(p.HTTPClient.Transport))
+}
+
+func (a AuthConfig) validate() error {
+	switch a.Type {
+	case "":
+		return nil
+	case "basic":
+		if a.Username == "" {
+			return errors.New("username missing")
+		}
+		return nil
+	case "bearer", "header", "query":
+		if a.Token == "" {
+			return errors.New("token missing")
+		}
+		if a.Type != "bearer" && a.Name == "" {
+			return errors.New("name missing")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown type %q, expected one of: basic, bearer, header, query", a.Type)
+}
+
+// credentialHeader reports whether the header name usually carries a
+// credential.
+func credentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "key", "token", "secret", "password"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+func compilePath(expr string) (*jsonpath.Path, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return jsonpath.Compile(expr)
+}
+
+// Capabilities returns what the configuration lets the provider send. The
+// sender and subject are passed on to the templates, which may ignore them.
+func (p *GenericHTTP) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes:  p.MessageTypes,
+		MaxTextLength: p.MaxTextLength,
+		Batch:         p.Batch,
+		SenderID:      true,
+		Subject:       true,
+	}
+}
+
+// requestData is what the request templates are executed with.
+type requestData struct {
+	// To is the recipient of the request, or all of them joined with the
+	// recipient separator in batch mode.
+	To         string
+	Recipients []string
+	From       string
+	Text       string
+	Type       string
+	Subject    string
+}
+
+// Send sends message and fails if it did not reach every recipient.
+func (p *GenericHTTP) Send(message sachet.Message) error {
+	return p.SendContext(context.Background(), message)
+}
+
+// SendContext is Send making its requests with ctx.
+func (p *GenericHTTP) SendContext(ctx context.Context, message sachet.Message) error {
+	results, err := p.SendResults(ctx, message)
+	if err != nil {
+		return err
+	}
+	return sachet.ResultsError(results)
+}
+
+// SendResults sends message in one request per recipient, or in a single
+// request in batch mode, and returns the message IDs found in the responses.
+func (p *GenericHTTP) SendResults(ctx context.Context, message sachet.Message) ([]sachet.Result, error) {
+	if !p.Capabilities().SupportsType(message.Type) {
+		return nil, fmt.Errorf("unknown message type %s", message.Type)
+	}
+
+	results := make([]sachet.Result, len(message.To))
+	if p.Batch {
+		ids, err := p.do(ctx, message, message.To)
+		for i, recipient := range message.To {
+			results[i] = sachet.Result{Recipient: recipient, Err: err}
+			if len(ids) == len(message.To) {
+				results[i].MessageID = ids[i]
+			}
+		}
+		return results, nil
+	}
+
+	for i, recipient := range message.To {
+		results[i].Recipient = recipient
+		ids, err := p.do(ctx, message, []string{recipient})
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if len(ids) > 0 {
+			results[i].MessageID = ids[0]
+		}
+	}
+	return results, nil
+}
+
+// do sends message to recipients in one request and returns the message IDs
+// of the response.
+func (p *GenericHTTP) do(ctx context.Context, message sachet.Message, recipients []string) ([]string, error) {
+	request, err := p.newRequest(ctx, requestData{
+		To:         strings.Join(recipients, p.RecipientSeparator),
+		Recipients: recipients,
+		From:       message.From,
+		Text:       message.Text,
+		Type:       message.Type,
+		Subject:    message.Subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, responseLimit))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	var doc interface{}
+	var decodeErr error
+	if p.successPath != nil || p.errorPath != nil || p.messageIDPath != nil {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		decodeErr = dec.Decode(&doc)
+	}
+
+	if !p.successStatus(response.StatusCode) {
+		err := fmt.Errorf("HTTP status code %d: %s", response.StatusCode, p.reason(doc, decodeErr, body))
+		if response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnprocessableEntity {
+			// The gateway refused this message, not every message.
+			err = sachet.Rejected(err)
+		}
+		return nil, err
+	}
+	if p.successPath != nil {
+		if decodeErr != nil {
+			return nil, fmt.Errorf("decoding response: %w", decodeErr)
+		}
+		if !p.successValue(p.successPath.Find(doc)) {
+			return nil, fmt.Errorf("%s does not indicate success: %s", p.successPath, p.reason(doc, nil, body))
+		}
+	}
+	if p.messageIDPath == nil {
+		return nil, nil
+	}
+	if decodeErr != nil {
+		// The message was sent, failing would only have it sent again.
+		slog.Debug("No message ID in the response of the gateway, it is not JSON", "status", response.StatusCode, "err", decodeErr)
+		return nil, nil
+	}
+	var ids []string
+	for _, v := range p.messageIDPath.Find(doc) {
+		ids = append(ids, jsonpath.String(v))
+	}
+	return ids, nil
+}
+
+func (p *GenericHTTP) newRequest(ctx context.Context, data requestData) (*http.Request, error) {
+	u, err := execute(p.url, data.pathEscaped())
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	for name, t := range p.params {
+		value, err := execute(t, data)
+		if err != nil {
+			return nil, err
+		}
+		params.Set(name, value)
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch p.Encoding {
+	case "json":
+		b, err := execute(p.body, data)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(b)) {
+			return nil, errors.New("body: rendered invalid JSON, use the json function to quote values")
+		}
+		body = strings.NewReader(b)
+		contentType = "application/json"
+	case "form":
+		body = strings.NewReader(params.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	request, err := http.NewRequestWithContext(ctx, p.Method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	query := request.URL.Query()
+	if p.Encoding == "query" {
+		for name, values := range params {
+			query[name] = values
+		}
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	request.Header.Set("User-Agent", "Sachet")
+
+	names := make([]string, 0, len(p.headers))
+	for name := range p.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := execute(p.headers[name], data)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set(name, value)
+	}
+
+	switch p.Auth.Type {
+	case "basic":
+		request.SetBasicAuth(p.Auth.Username, string(p.Auth.Password))
+	case "bearer":
+		request.Header.Set("Authorization", "Bearer "+string(p.Auth.Token))
+	case "header":
+		request.Header.Set(p.Auth.Name, string(p.Auth.Token))
+	case "query":
+		query.Set(p.Auth.Name, string(p.Auth.Token))
+	}
+	request.URL.RawQuery = query.Encode()
+	return request, nil
+}
+
+// pathEscaped returns data with its values escaped as URL path segments, so
+// that a recipient containing / or ? cannot change the URL it is sent to.
+func (data requestData) pathEscaped() requestData {
+	escaped := requestData{
+		To:         url.PathEscape(data.To),
+		Recipients: make([]string, len(data.Recipients)),
+		From:       url.PathEscape(data.From),
+		Text:       url.PathEscape(data.Text),
+		Type:       url.PathEscape(data.Type),
+		Subject:    url.PathEscape(data.Subject),
+	}
+	for i, recipient := range data.Recipients {
+		escaped.Recipients[i] = url.PathEscape(recipient)
+	}
+	return escaped
+}
+
+func execute(t *template.Template, data requestData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (p *GenericHTTP) successStatus(code int) bool {
+	if len(p.Success.StatusCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range p.Success.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *GenericHTTP) successValue(values []interface{}) bool {
+	for _, v := range values {
+		if len(p.Success.Values) == 0 {
+			switch s := jsonpath.String(v); s {
+			case "", "false", "0":
+			default:
+				return true
+			}
+			continue
+		}
+		for _, want := range p.Success.Values {
+			if jsonpath.String(v) == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reason describes why the gateway failed a request: the value selected by
+// the error path if there is one, and otherwise the start of the response.
+func (p *GenericHTTP) reason(doc interface{}, decodeErr error, body []byte) string {
+	if p.errorPath != nil && decodeErr == nil {
+		if s, ok := p.errorPath.FindString(doc); ok && s != "" {
+			return s
+		}
+	}
+	const max = 200
+	s := []rune(strings.TrimSpace(string(body)))
+	if len(s) > max {
+		return string(s[:max]) + "..."
+	}
+	return string(s)
+}
@@ -0,0 +1,296 @@
+package generichttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
+)
+
+// request is what the test server received.
+type request struct {
+	method, path, query, contentType, auth, body string
+}
+
+func TestGenericHTTP_SendResults(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		config   Config
+		response string
+		status   int
+		want     []request
+		results  []sachet.Result
+	}{
+		{
+			name: "json batch",
+			config: Config{
+				URL:           "/sms",
+				Auth:          AuthConfig{Type: "bearer", Token: "t0k3n"},
+				Body:          `{"to": {{ json .Recipients }}, "text": {{ json .Text }}}`,
+				Batch:         true,
+				Success:       SuccessConfig{Path: "$.status", Values: []string{"queued"}},
+				MessageIDPath: "$.messages[*].id",
+			},
+			response: `{"status": "queued", "messages": [{"id": "a1"}, {"id": "b2"}]}`,
+			want: []request{
+				{method: "POST", path: "/sms", contentType: "application/json", auth: "Bearer t0k3n", body: `{"to": ["+31612345678","+31687654321"], "text": "CPU \"high\""}`},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678", MessageID: "a1"},
+				{Recipient: "+31687654321", MessageID: "b2"},
+			},
+		},
+		{
+			name: "form per recipient",
+			config: Config{
+				URL:           "/send/{{ .To }}",
+				Encoding:      "form",
+				Params:        map[string]string{"msg": "{{ .Text }}", "from": "{{ .From }}"},
+				MessageIDPath: "$.id",
+			},
+			response: `{"id": 42}`,
+			want: []request{
+				{method: "POST", path: "/send/+31612345678", contentType: "application/x-www-form-urlencoded", body: `from=SACHET&msg=CPU+%22high%22`},
+				{method: "POST", path: "/send/+31687654321", contentType: "application/x-www-form-urlencoded", body: `from=SACHET&msg=CPU+%22high%22`},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678", MessageID: "42"},
+				{Recipient: "+31687654321", MessageID: "42"},
+			},
+		},
+		{
+			name: "batch message ids mismatch",
+			config: Config{
+				URL:           "/sms",
+				Body:          `{"to": {{ json .Recipients }}}`,
+				Batch:         true,
+				MessageIDPath: "$.id",
+			},
+			response: `{"id": "a1"}`,
+			want: []request{
+				{method: "POST", path: "/sms", contentType: "application/json", body: `{"to": ["+31612345678","+31687654321"]}`},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678"},
+				{Recipient: "+31687654321"},
+			},
+		},
+		{
+			name: "not json",
+			config: Config{
+				URL:           "/sms",
+				Body:          `{}`,
+				Batch:         true,
+				MessageIDPath: "$.id",
+			},
+			response: "OK\n",
+			want: []request{
+				{method: "POST", path: "/sms", contentType: "application/json", body: `{}`},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678"},
+				{Recipient: "+31687654321"},
+			},
+		},
+		{
+			name: "query failing",
+			config: Config{
+				URL:      "/api?action=send",
+				Encoding: "query",
+				Params:   map[string]string{"to": "{{ .To }}"},
+				Auth:     AuthConfig{Type: "query", Name: "key", Token: "s3cret"},
+				Batch:    true,
+				Success:  SuccessConfig{Path: "$.ok", ErrorPath: "$.error.message"},
+			},
+			response: `{"ok": false, "error": {"message": "insufficient credit"}}`,
+			want: []request{
+				{method: "GET", path: "/api", query: "action=send&key=s3cret&to=%2B31612345678%2C%2B31687654321"},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678", Err: assert.AnError},
+				{Recipient: "+31687654321", Err: assert.AnError},
+			},
+		},
+		{
+			name: "status code",
+			config: Config{
+				URL:  "/sms",
+				Body: `{}`,
+				Auth: AuthConfig{Type: "basic", Username: "user", Password: "pass"},
+			},
+			status:   http.StatusUnauthorized,
+			response: "bad credentials\n",
+			want: []request{
+				{method: "POST", path: "/sms", contentType: "application/json", auth: "Basic dXNlcjpwYXNz", body: `{}`},
+				{method: "POST", path: "/sms", contentType: "application/json", auth: "Basic dXNlcjpwYXNz", body: `{}`},
+			},
+			results: []sachet.Result{
+				{Recipient: "+31612345678", Err: assert.AnError},
+				{Recipient: "+31687654321", Err: assert.AnError},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				got = append(got, request{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)})
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				io.WriteString(w, tc.response)
+			}))
+			defer server.Close()
+
+			tc.config.URL = server.URL + tc.config.URL
+			p, err := NewGenericHTTP(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := p.(sachet.ResultProvider).SendResults(context.Background(), sachet.Message{
+				To:   []string{"+31612345678", "+31687654321"},
+				From: "SACHET",
+				Text: `CPU "high"`,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.want, got)
+			if !assert.Len(t, results, len(tc.results)) {
+				return
+			}
+			for i, want := range tc.results {
+				assert.Equal(t, want.Recipient, results[i].Recipient)
+				assert.Equal(t, want.MessageID, results[i].MessageID)
+				assert.Equal(t, want.Err != nil, results[i].Err != nil, results[i].Err)
+			}
+		})
+	}
+}
+
+func TestGenericHTTP_errors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": "unknown number"}`)
+	}))
+	defer server.Close()
+
+	p, err := NewGenericHTTP(Config{URL: server.URL, Body: `{"text": {{ json .Text }}}`, Success: SuccessConfig{ErrorPath: "$.error"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, p.Send(sachet.Message{To: []string{"1"}, Text: "hi"}), "sending to 1 of 1 recipients failed: HTTP status code 400: unknown number")
+	results, err := p.(sachet.ResultProvider).SendResults(context.Background(), sachet.Message{To: []string{"1"}, Text: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, sachet.IsRejected(results[0].Err), "400 rejects the message")
+
+	p, err = NewGenericHTTP(Config{URL: server.URL, Body: `{"text": "{{ .Text }}"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, p.Send(sachet.Message{To: []string{"1"}, Text: `say "hi"`}), "sending to 1 of 1 recipients failed: body: rendered invalid JSON, use the json function to quote values")
+}
+
+func TestNewGenericHTTP(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"url", Config{Body: "{}"}, "url missing"},
+		{"encoding", Config{URL: "/", Encoding: "xml"}, `encoding: unknown encoding "xml", expected one of: json, form, query`},
+		{"body", Config{URL: "/"}, "body missing for the json encoding"},
+		{"form body", Config{URL: "/", Encoding: "form", Body: "{}"}, "body: not sent with the form encoding, use params"},
+		{"form params", Config{URL: "/", Encoding: "form"}, "params missing for the form encoding"},
+		{"template", Config{URL: "/", Body: "{{ .To "}, `body: template: body:1: unclosed action`},
+		{"auth", Config{URL: "/", Body: "{}", Auth: AuthConfig{Type: "header", Token: "x"}}, "auth: name missing"},
+		{"auth type", Config{URL: "/", Body: "{}", Auth: AuthConfig{Type: "digest"}}, `auth: unknown type "digest", expected one of: basic, bearer, header, query`},
+		{"success path", Config{URL: "/", Body: "{}", Success: SuccessConfig{Path: "status"}}, `success.path: jsonpath "status": must start with $`},
+		{"success values", Config{URL: "/", Body: "{}", Success: SuccessConfig{Values: []string{"ok"}}}, "success.values: requires success.path"},
+		{"phone number format", Config{URL: "/", Body: "{}", PhoneNumberFormat: "e.164"}, `phone_number_format: unknown format "e.164", expected one of: e164, e164_without_plus, national`},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewGenericHTTP(tc.config)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+
+	p, err := NewGenericHTTP(Config{URL: "/", Body: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := p.(sachet.PhoneNumberProvider)
+	assert.False(t, ok)
+
+	p, err = NewGenericHTTP(Config{URL: "/", Body: "{}", PhoneNumberFormat: "national"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, phonenumber.National, p.(sachet.PhoneNumberProvider).PhoneNumberFormat())
+}
+
+func TestGenericHTTP_newRequest(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewGenericHTTP(Config{URL: "https://chat.example.org/rooms/{{ .To }}/messages", Body: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := p.(*GenericHTTP).newRequest(context.Background(), requestData{To: "ops/../admin?x#y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/rooms/ops%2F..%2Fadmin%3Fx%23y/messages", request.URL.EscapedPath())
+}
+
+func TestGenericHTTP_SendContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p, err := NewGenericHTTP(Config{URL: "http://127.0.0.1:1/sms", Body: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, p.(sachet.ContextProvider).SendContext(ctx, sachet.Message{To: []string{"1"}}), context.Canceled)
+}
+
+func TestCredentialHeader(t *testing.T) {
+	t.Parallel()
+
+	for name, exp := range map[string]bool{
+		"Authorization": true,
+		"X-API-Key":     true,
+		"X-Auth-Token":  true,
+		"Content-Type":  false,
+		"X-Request-ID":  false,
+	} {
+		assert.Equal(t, exp, credentialHeader(name), name)
+	}
+}
@@ -1,6 +1,7 @@
 package ghasedak
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -9,14 +10,19 @@ import (
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 // Retrieving required data from 'ghasedak' sections of config.yaml.
 type Config struct {
-	APIToken string `yaml:"api_token"`
+	APIToken     sachet.Secret `yaml:"api_token"`
+	APITokenFile string        `yaml:"api_token_file"`
 }
 
 var _ (sachet.Provider) = (*Ghasedak)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Ghasedak)(nil)
+var _ (sachet.ContextProvider) = (*Ghasedak)(nil)
+var _ (sachet.HTTPProvider) = (*Ghasedak)(nil)
 
 // Creating the KaveNegar to contain provider data.
 type Ghasedak struct {
@@ -32,19 +38,29 @@ func NewGhasedak(config Config) *Ghasedak {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to Ghasedak.
+func (ns *Ghasedak) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	ns.HTTPClient.Transport = wrap(sachet.TransportOrDefault(ns.HTTPClient.Transport))
+}
+
 // Building the API and call the Ghasedak endpoint to send SMS to the configured receptor from config.yaml.
 func (ns *Ghasedak) Send(message sachet.Message) error {
+	return ns.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (ns *Ghasedak) SendContext(ctx context.Context, message sachet.Message) error {
 	endpoint := "https://api.ghasedak.me/v2/sms/send/pair"
 	data := url.Values{}
 	data.Set("message", message.Text)
 	data.Set("receptor", strings.Join(message.To, ","))
-	request, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
+	request, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(data.Encode()))
 	if err != nil {
 		return err
 	}
 	request.Header.Set("User-Agent", "Sachet")
 	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("apikey", ns.APIToken)
+	request.Header.Add("apikey", string(ns.APIToken))
 	request.Header.Add("cache-control", "no-cache")
 	response, err := ns.HTTPClient.Do(request)
 	if err != nil {
@@ -63,3 +79,8 @@ func (ns *Ghasedak) Send(message sachet.Message) error {
 	fmt.Println("Message sent: ", message.Text)
 	return nil
 }
+
+// PhoneNumberFormat returns the format Ghasedak expects recipient numbers in.
+func (ns *Ghasedak) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.National
+}
@@ -2,28 +2,35 @@ package infobip
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 // Config configuration struct for Infobip Client.
 type Config struct {
-	Token  string `yaml:"token"`
-	Secret string `yaml:"secret"`
+	Token      string        `yaml:"token"`
+	Secret     sachet.Secret `yaml:"secret"`
+	SecretFile string        `yaml:"secret_file"`
 }
 
 // InfobipRequestTimeout  is the timeout for http request to Infobip.
 const InfobipRequestTimeout = time.Second * 20
 
 var _ (sachet.Provider) = (*Infobip)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Infobip)(nil)
+var _ (sachet.ContextProvider) = (*Infobip)(nil)
+var _ (sachet.HTTPProvider) = (*Infobip)(nil)
 
 // Infobip is the exte Infobip.
 type Infobip struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 type InfobipDestination struct {
@@ -42,12 +49,22 @@ type InfobipPayload struct {
 
 // NewInfobip creates a new.
 func NewInfobip(config Config) *Infobip {
-	Infobip := &Infobip{config}
+	Infobip := &Infobip{config, &http.Client{Timeout: InfobipRequestTimeout}}
 	return Infobip
 }
 
+// WrapTransport wraps the transport of the requests to Infobip.
+func (c *Infobip) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
 // Send send sms to n number of people using bulk sms api.
-func (c *Infobip) Send(message sachet.Message) (err error) {
+func (c *Infobip) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Infobip) SendContext(ctx context.Context, message sachet.Message) (err error) {
 	smsURL := "https://api.infobip.com/sms/2/text/advanced"
 	// smsURL = "http://requestb.in/pwf2ufpw"
 	var request *http.Request
@@ -73,19 +90,16 @@ func (c *Infobip) Send(message sachet.Message) (err error) {
 	}
 
 	// preparing the request.
-	request, err = http.NewRequest("POST", smsURL, bytes.NewBuffer(data))
+	request, err = http.NewRequestWithContext(ctx, "POST", smsURL, bytes.NewBuffer(data))
 	if err != nil {
 		return
 	}
 
-	request.SetBasicAuth(c.Token, c.Secret)
+	request.SetBasicAuth(c.Token, string(c.Secret))
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("User-Agent", "SachetV1.0")
 	// calling the endpoint.
-	httpClient := &http.Client{}
-	httpClient.Timeout = InfobipRequestTimeout
-
-	resp, err = httpClient.Do(request)
+	resp, err = c.HTTPClient.Do(request)
 	if err != nil {
 		return
 	}
@@ -100,3 +114,8 @@ func (c *Infobip) Send(message sachet.Message) (err error) {
 	}
 	return fmt.Errorf("Failed sending sms:Reason: %s , StatusCode : %d", string(body), resp.StatusCode)
 }
+
+// PhoneNumberFormat returns the format Infobip expects recipient numbers in.
+func (c *Infobip) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164WithoutPlus
+}
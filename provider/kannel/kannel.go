@@ -1,6 +1,7 @@
 package kannel
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,39 +12,78 @@ import (
 
 // Config configuration struct for Kannel Client.
 type Config struct {
-	URL  string `yaml:"url"`
-	User string `yaml:"username"`
-	Pass string `yaml:"password"`
+	URL      string        `yaml:"url"`
+	User     string        `yaml:"username"`
+	Pass     sachet.Secret `yaml:"password"`
+	PassFile string        `yaml:"password_file"`
+
+	// StatusURL is the status page of the Kannel admin interface, such as
+	// http://kannel:13000/status.txt?password=secret, used as health check.
+	StatusURL     sachet.Secret `yaml:"status_url"`
+	StatusURLFile string        `yaml:"status_url_file"`
 }
 
 // KannelRequestTimeout  is the timeout for http request to Kannel.
 const KannelRequestTimeout = time.Second * 20
 
 var _ (sachet.Provider) = (*Kannel)(nil)
+var _ (sachet.HealthChecker) = (*Kannel)(nil)
+var _ (sachet.ContextProvider) = (*Kannel)(nil)
+var _ (sachet.HTTPProvider) = (*Kannel)(nil)
 
 // Kannel is the exte Kannel.
 type Kannel struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 // NewKannel creates a new.
 func NewKannel(config Config) *Kannel {
-	Kannel := &Kannel{config}
+	Kannel := &Kannel{config, &http.Client{Timeout: KannelRequestTimeout}}
 	return Kannel
 }
 
+// WrapTransport wraps the transport of the requests to Kannel.
+func (c *Kannel) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
+// HealthCheck fetches the status page of Kannel if a status URL is
+// configured.
+func (c *Kannel) HealthCheck() error {
+	if c.StatusURL == "" {
+		return nil
+	}
+
+	response, err := c.HTTPClient.Get(string(c.StatusURL))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("status page returned status code %d", response.StatusCode)
+	}
+	return nil
+}
+
 // Send send sms to n number of people using bulk sms api.
 func (c *Kannel) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Kannel) SendContext(ctx context.Context, message sachet.Message) error {
 	for _, recipient := range message.To {
 		queryParams := url.Values{
 			"from": {message.From},
 			"to":   {recipient},
 			"text": {message.Text},
 			"user": {c.User},
-			"pass": {c.Pass},
+			"pass": {string(c.Pass)},
 		}
 
-		request, err := http.NewRequest("GET", c.URL, nil)
+		request, err := http.NewRequestWithContext(ctx, "GET", c.URL, nil)
 		if err != nil {
 			return err
 		}
@@ -53,10 +93,7 @@ func (c *Kannel) Send(message sachet.Message) error {
 		request.Header.Set("User-Agent", "SachetV1.0")
 		//	calling the endpoint - print out Kannel requested URL for debug purpose
 		// fmt.Println(request.URL.String())
-		httpClient := &http.Client{}
-		httpClient.Timeout = KannelRequestTimeout
-
-		response, err := httpClient.Do(request)
+		response, err := c.HTTPClient.Do(request)
 		if err != nil {
 			return err
 		}
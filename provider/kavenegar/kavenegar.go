@@ -1,6 +1,7 @@
 package kavenegar
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -8,15 +9,20 @@ import (
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 // Retrieving required data from 'kavenegar' sections of config.yaml.
 type Config struct {
-	APIToken     string   `yaml:"api_token"`
-	PhoneNumbers []string `yaml:"phone_numbers"`
+	APIToken     sachet.Secret `yaml:"api_token"`
+	APITokenFile string        `yaml:"api_token_file"`
+	PhoneNumbers []string      `yaml:"phone_numbers"`
 }
 
 var _ (sachet.Provider) = (*KaveNegar)(nil)
+var _ (sachet.PhoneNumberProvider) = (*KaveNegar)(nil)
+var _ (sachet.ContextProvider) = (*KaveNegar)(nil)
+var _ (sachet.HTTPProvider) = (*KaveNegar)(nil)
 
 // Creating the KaveNegar to contain provider data.
 type KaveNegar struct {
@@ -32,10 +38,20 @@ func NewKaveNegar(config Config) *KaveNegar {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to KaveNegar.
+func (ns *KaveNegar) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	ns.HTTPClient.Transport = wrap(sachet.TransportOrDefault(ns.HTTPClient.Transport))
+}
+
 // Building the API and call the KaveNegar endpoint to send SMS to the configured receptor from config.yaml.
 func (ns *KaveNegar) Send(message sachet.Message) error {
-	url := "https://api.kavenegar.com/v1/" + ns.APIToken + "/sms/send.json"
-	request, err := http.NewRequest("GET", url, nil)
+	return ns.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (ns *KaveNegar) SendContext(ctx context.Context, message sachet.Message) error {
+	url := "https://api.kavenegar.com/v1/" + string(ns.APIToken) + "/sms/send.json"
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
@@ -64,3 +80,8 @@ func (ns *KaveNegar) Send(message sachet.Message) error {
 	fmt.Println("Message sent: ", message.Text)
 	return nil
 }
+
+// PhoneNumberFormat returns the format KaveNegar expects recipient numbers in.
+func (ns *KaveNegar) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.National
+}
@@ -1,36 +1,63 @@
 package mailruim
 
 import (
+	"sync"
+
 	botgolang "github.com/mail-ru-im/bot-golang"
 
 	"github.com/messagebird/sachet"
 )
 
 type Config struct {
-	Token string `yaml:"token"`
-	Url   string `yaml:"url"`
+	Token     sachet.Secret `yaml:"token"`
+	TokenFile string        `yaml:"token_file"`
+	Url       string        `yaml:"url"`
 }
 
-var _ (sachet.Provider) = (*MailruIM)(nil)
+var _ (sachet.CapableProvider) = (*MailruIM)(nil)
 
 type MailruIM struct {
+	config Config
+
+	mu  sync.Mutex
 	bot *botgolang.Bot
 }
 
-func NewMailruIM(config Config) (*MailruIM, error) {
-	bot, err := botgolang.NewBot(config.Token, botgolang.BotApiURL(config.Url))
-	if err != nil {
-		return nil, err
+// NewMailruIM creates a Mail.ru IM provider. The bot is connected on the first
+// Send, as botgolang.NewBot contacts the API.
+func NewMailruIM(config Config) *MailruIM {
+	return &MailruIM{config: config}
+}
+
+func (mr *MailruIM) getBot() (*botgolang.Bot, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.bot == nil {
+		bot, err := botgolang.NewBot(string(mr.config.Token), botgolang.BotApiURL(mr.config.Url))
+		if err != nil {
+			return nil, err
+		}
+		mr.bot = bot
 	}
+	return mr.bot, nil
+}
 
-	return &MailruIM{
-		bot: bot,
-	}, nil
+// Capabilities returns what the Mail.ru IM provider supports.
+func (mr *MailruIM) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text"},
+	}
 }
 
 func (mr *MailruIM) Send(message sachet.Message) error {
+	bot, err := mr.getBot()
+	if err != nil {
+		return err
+	}
+
 	for _, ChatID := range message.To {
-		msg := mr.bot.NewTextMessage(ChatID, message.Text)
+		msg := bot.NewTextMessage(ChatID, message.Text)
 		if err := msg.Send(); err != nil {
 			// TODO: handle the error
 		}
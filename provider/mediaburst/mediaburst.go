@@ -1,6 +1,7 @@
 package mediaburst
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,35 +13,49 @@ import (
 
 // Config configuration struct for mediaburst Client.
 type Config struct {
-	APIKey string `yaml:"api_key"`
+	APIKey     sachet.Secret `yaml:"api_key"`
+	APIKeyFile string        `yaml:"api_key_file"`
 }
 
 // MediaBurstRequestTimeout  is the timeout for http request to mediaburst.
 const MediaBurstRequestTimeout = time.Second * 20
 
 var _ (sachet.Provider) = (*MediaBurst)(nil)
+var _ (sachet.ContextProvider) = (*MediaBurst)(nil)
+var _ (sachet.HTTPProvider) = (*MediaBurst)(nil)
 
 // MediaBurst is the exte MediaBurst.
 type MediaBurst struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 // NewMediaBurst creates a new.
 func NewMediaBurst(config Config) *MediaBurst {
-	MediaBurst := &MediaBurst{config}
+	MediaBurst := &MediaBurst{config, &http.Client{Timeout: MediaBurstRequestTimeout}}
 	return MediaBurst
 }
 
+// WrapTransport wraps the transport of the requests to Mediaburst.
+func (c *MediaBurst) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
 // Send send sms to n number of people using bulk sms api.
-func (c *MediaBurst) Send(message sachet.Message) (err error) {
+func (c *MediaBurst) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *MediaBurst) SendContext(ctx context.Context, message sachet.Message) (err error) {
 	smsURL := "https://api.clockworksms.com/http/send.aspx"
 	var request *http.Request
 	var resp *http.Response
 
-	form := url.Values{"Key": {c.APIKey}, "From": {message.From}, "Content": {message.Text}, "To": message.To}
+	form := url.Values{"Key": {string(c.APIKey)}, "From": {message.From}, "Content": {message.Text}, "To": message.To}
 
 	// preparing the request.
-	request, err = http.NewRequest("GET", smsURL, strings.NewReader(form.Encode()))
+	request, err = http.NewRequestWithContext(ctx, "GET", smsURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return
 	}
@@ -48,10 +63,7 @@ func (c *MediaBurst) Send(message sachet.Message) (err error) {
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.Header.Set("User-Agent", "SachetV1.0")
 	// calling the endpoint.
-	httpClient := &http.Client{}
-	httpClient.Timeout = MediaBurstRequestTimeout
-
-	resp, err = httpClient.Do(request)
+	resp, err = c.HTTPClient.Do(request)
 	if err != nil {
 		return
 	}
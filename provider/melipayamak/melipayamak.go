@@ -2,6 +2,7 @@ package melipayamak
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,16 +11,21 @@ import (
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 type Config struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	From     string `yaml:"from"`
-	Endpoint string `yaml:"endpoint"`
+	Username     string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	From         string        `yaml:"from"`
+	Endpoint     string        `yaml:"endpoint"`
 }
 
 var _ (sachet.Provider) = (*Melipayamak)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Melipayamak)(nil)
+var _ (sachet.ContextProvider) = (*Melipayamak)(nil)
+var _ (sachet.HTTPProvider) = (*Melipayamak)(nil)
 
 type Melipayamak struct {
 	Config
@@ -33,17 +39,27 @@ func NewMelipayamak(config Config) *Melipayamak {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to Melipayamak.
+func (mp *Melipayamak) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	mp.HTTPClient.Transport = wrap(sachet.TransportOrDefault(mp.HTTPClient.Transport))
+}
+
 func (mp *Melipayamak) Send(message sachet.Message) error {
+	return mp.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (mp *Melipayamak) SendContext(ctx context.Context, message sachet.Message) error {
 
 	Payload := map[string]string{
 		"username": mp.Username,
-		"password": mp.Password,
+		"password": string(mp.Password),
 		"to":       strings.Join(message.To, ","),
 		"from":     message.From,
 		"text":     message.Text,
 	}
 	data, _ := json.Marshal(Payload)
-	request, err := http.NewRequest("POST", mp.Endpoint, bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(ctx, "POST", mp.Endpoint, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
@@ -64,3 +80,8 @@ func (mp *Melipayamak) Send(message sachet.Message) error {
 	}
 	return nil
 }
+
+// PhoneNumberFormat returns the format Melipayamak expects recipient numbers in.
+func (mp *Melipayamak) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.National
+}
@@ -1,27 +1,36 @@
 package messagebird
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"net/http"
 
 	messagebird "github.com/messagebird/go-rest-api"
 	sms "github.com/messagebird/go-rest-api/sms"
 	voicemessage "github.com/messagebird/go-rest-api/voicemessage"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 type Config struct {
-	AccessKey string `yaml:"access_key"`
-	Gateway   int    `yaml:"gateway"`
-	Debug     bool   `yaml:"debug"`
-	Language  string `yaml:"language"`
-	Voice     string `yaml:"voice"`
-	Repeat    int    `yaml:"repeat"`
+	AccessKey     sachet.Secret `yaml:"access_key"`
+	AccessKeyFile string        `yaml:"access_key_file"`
+	Gateway       int           `yaml:"gateway"`
+	Language      string        `yaml:"language"`
+	Voice         string        `yaml:"voice"`
+	Repeat        int           `yaml:"repeat"`
+
+	// Deprecated: Debug is ignored. Run Sachet with -log-level=debug to log
+	// the requests made to MessageBird.
+	Debug bool `yaml:"debug,omitempty"`
 }
 
 var _ (sachet.Provider) = (*MessageBird)(nil)
+var _ (sachet.PhoneNumberProvider) = (*MessageBird)(nil)
+var _ (sachet.CapableProvider) = (*MessageBird)(nil)
+var _ (sachet.ResultProvider) = (*MessageBird)(nil)
+var _ (sachet.HTTPProvider) = (*MessageBird)(nil)
 
 type MessageBird struct {
 	client             *messagebird.Client
@@ -30,12 +39,8 @@ type MessageBird struct {
 }
 
 func NewMessageBird(config Config) *MessageBird {
-	client := messagebird.New(config.AccessKey)
-	if config.Debug {
-		client.DebugLog = log.New(os.Stdout, "DEBUG: ", log.Lshortfile)
-	}
 	return &MessageBird{
-		client: client,
+		client: messagebird.New(string(config.AccessKey)),
 		messageParams: sms.Params{
 			Gateway: config.Gateway,
 		},
@@ -47,14 +52,59 @@ func NewMessageBird(config Config) *MessageBird {
 	}
 }
 
-func (mb *MessageBird) Send(message sachet.Message) (err error) {
+// WrapTransport wraps the transport of the requests to MessageBird.
+func (mb *MessageBird) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	mb.client.HTTPClient.Transport = wrap(sachet.TransportOrDefault(mb.client.HTTPClient.Transport))
+}
+
+// Capabilities returns what the MessageBird provider supports.
+func (mb *MessageBird) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text", "voice"},
+		Batch:        true,
+		SenderID:     true,
+	}
+}
+
+func (mb *MessageBird) Send(message sachet.Message) error {
+	_, err := mb.SendResults(context.Background(), message)
+	return err
+}
+
+// SendResults sends message to all recipients at once, so they all get the
+// ID of the created message. The MessageBird client does not take a context,
+// so ctx is only checked before sending.
+func (mb *MessageBird) SendResults(ctx context.Context, message sachet.Message) ([]sachet.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var id string
 	switch message.Type {
 	case "", "text":
-		_, err = sms.Create(mb.client, message.From, message.To, message.Text, &mb.messageParams)
+		msg, err := sms.Create(mb.client, message.From, message.To, message.Text, &mb.messageParams)
+		if err != nil {
+			return nil, err
+		}
+		id = msg.ID
 	case "voice":
-		_, err = voicemessage.Create(mb.client, message.To, message.Text, &mb.voiceMessageParams)
+		msg, err := voicemessage.Create(mb.client, message.To, message.Text, &mb.voiceMessageParams)
+		if err != nil {
+			return nil, err
+		}
+		id = msg.ID
 	default:
-		return fmt.Errorf("unknown message type %s", message.Type)
+		return nil, fmt.Errorf("unknown message type %s", message.Type)
 	}
-	return err
+
+	results := make([]sachet.Result, len(message.To))
+	for i, recipient := range message.To {
+		results[i] = sachet.Result{Recipient: recipient, MessageID: id}
+	}
+	return results, nil
+}
+
+// PhoneNumberFormat returns the format MessageBird expects recipient numbers in.
+func (mb *MessageBird) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164
 }
@@ -4,21 +4,24 @@ import (
 	nexmo "gopkg.in/njern/gonexmo.v1"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 type Config struct {
-	APIKey    string `yaml:"api_key"`
-	APISecret string `yaml:"api_secret"`
+	APIKey        string        `yaml:"api_key"`
+	APISecret     sachet.Secret `yaml:"api_secret"`
+	APISecretFile string        `yaml:"api_secret_file"`
 }
 
 var _ (sachet.Provider) = (*Nexmo)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Nexmo)(nil)
 
 type Nexmo struct {
 	client *nexmo.Client
 }
 
 func NewNexmo(config Config) (*Nexmo, error) {
-	client, err := nexmo.NewClientFromAPI(config.APIKey, config.APISecret)
+	client, err := nexmo.NewClientFromAPI(config.APIKey, string(config.APISecret))
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +46,8 @@ func (nx *Nexmo) Send(message sachet.Message) error {
 
 	return nil
 }
+
+// PhoneNumberFormat returns the format Nexmo expects recipient numbers in.
+func (nx *Nexmo) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164WithoutPlus
+}
@@ -1,6 +1,7 @@
 package nowsms
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -12,12 +13,15 @@ import (
 
 // Config is the configuration struct for NowSms provider.
 type Config struct {
-	User         string   `yaml:"username"`
-	Password     string   `yaml:"password"`
-	PhoneNumbers []string `yaml:"phone_numbers"`
+	User         string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	PhoneNumbers []string      `yaml:"phone_numbers"`
 }
 
 var _ (sachet.Provider) = (*NowSms)(nil)
+var _ (sachet.ContextProvider) = (*NowSms)(nil)
+var _ (sachet.HTTPProvider) = (*NowSms)(nil)
 
 // NowSms contains the necessary values for the NowSms provider.
 type NowSms struct {
@@ -33,11 +37,21 @@ func NewNowSms(config Config) *NowSms {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to NowSMS.
+func (ns *NowSms) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	ns.HTTPClient.Transport = wrap(sachet.TransportOrDefault(ns.HTTPClient.Transport))
+}
+
 // Send sends SMS to user registered in configuration.
 func (ns *NowSms) Send(message sachet.Message) error {
+	return ns.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (ns *NowSms) SendContext(ctx context.Context, message sachet.Message) error {
 	const nowSmsURL = "http://sms-gateway:8800/send"
 
-	request, err := http.NewRequest("POST", nowSmsURL, nil)
+	request, err := http.NewRequestWithContext(ctx, "POST", nowSmsURL, nil)
 	if err != nil {
 		return err
 	}
@@ -45,7 +59,7 @@ func (ns *NowSms) Send(message sachet.Message) error {
 
 	params := request.URL.Query()
 	params.Add("User", ns.User)
-	params.Add("Password", ns.Password)
+	params.Add("Password", string(ns.Password))
 	params.Add("PhoneNumber", strings.Join(message.To, ","))
 	params.Add("Text", message.Text)
 	request.URL.RawQuery = params.Encode()
@@ -2,27 +2,29 @@ package otc
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 type Config struct {
-	IdentityEndpoint string `yaml:"identity_endpoint"`
-	DomainName       string `yaml:"domain_name"`
-	ProjectName      string `yaml:"project_name"`
-	UserName         string `yaml:"username"`
-	Password         string `yaml:"password"`
-	ProjectID        string `yaml:"project_id"`
-	Insecure         bool   `yaml:"insecure"`
-	Token            string `yaml:"-"`
-	OtcBaseURL       string `yaml:"-"`
+	IdentityEndpoint string        `yaml:"identity_endpoint"`
+	DomainName       string        `yaml:"domain_name"`
+	ProjectName      string        `yaml:"project_name"`
+	UserName         string        `yaml:"username"`
+	Password         sachet.Secret `yaml:"password"`
+	PasswordFile     string        `yaml:"password_file"`
+	ProjectID        string        `yaml:"project_id"`
+	Insecure         bool          `yaml:"insecure"`
 }
 
 type smsRequest struct {
@@ -30,16 +32,66 @@ type smsRequest struct {
 	Message  string `json:"message"`
 }
 
+var _ (sachet.PhoneNumberProvider) = (*OTC)(nil)
+var _ (sachet.HealthChecker) = (*OTC)(nil)
+var _ (sachet.ContextProvider) = (*OTC)(nil)
+var _ (sachet.HTTPProvider) = (*OTC)(nil)
+
 type OTC struct {
 	Config
+	client *http.Client
+
+	// mu guards the token and the SMN endpoint it was issued for, which
+	// health checks and concurrent sends renew.
+	mu      sync.Mutex
+	token   string
+	baseURL string
 }
 
 func NewOTC(config Config) *OTC {
-	OTC := &OTC{config}
-	return OTC
+	client := &http.Client{Timeout: 10 * time.Second}
+	if config.Insecure {
+		// Insecure gets its own transport, as http.DefaultTransport is
+		// shared with the other providers.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+	return &OTC{Config: config, client: client}
+}
+
+// WrapTransport wraps the transport of the requests to OTC.
+func (c *OTC) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.client.Transport = wrap(sachet.TransportOrDefault(c.client.Transport))
+}
+
+// session returns the current token and SMN endpoint, logging in first if
+// there is no token.
+func (c *OTC) session(ctx context.Context) (token, baseURL string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" {
+		token, baseURL, err := c.loginRequest(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		c.token, c.baseURL = token, baseURL
+	}
+	return c.token, c.baseURL, nil
 }
 
-func (c *OTC) loginRequest() error {
+// expire forgets token, unless it has already been replaced by a newer one.
+func (c *OTC) expire(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// loginRequest acquires a new token and returns it with the SMN endpoint it
+// was issued for.
+func (c *OTC) loginRequest(ctx context.Context) (token, baseURL string, err error) {
 	type nameResponse struct {
 		Name string `json:"name"`
 	}
@@ -73,7 +125,7 @@ func (c *OTC) loginRequest() error {
 
 	userResp := userResponse{
 		Name:     c.UserName,
-		Password: c.Password,
+		Password: string(c.Password),
 		Domain: nameResponse{
 			Name: c.DomainName,
 		},
@@ -97,32 +149,27 @@ func (c *OTC) loginRequest() error {
 
 	body, err := json.Marshal(loginResp)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	req, err := http.NewRequest("POST", c.IdentityEndpoint, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.IdentityEndpoint, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	tr := http.DefaultTransport.(*http.Transport)
-	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: c.Insecure}
-
-	client := &http.Client{Timeout: 10 * time.Second, Transport: tr}
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("OTC API request failed with HTTP status code %d", resp.StatusCode)
+		return "", "", fmt.Errorf("OTC API request failed with HTTP status code %d", resp.StatusCode)
 	}
 
-	c.Token = resp.Header.Get("X-Subject-Token")
-
-	if c.Token == "" {
-		return fmt.Errorf("unable to get auth token")
+	token = resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", "", fmt.Errorf("unable to get auth token")
 	}
 
 	type endpointResponse struct {
@@ -141,70 +188,73 @@ func (c *OTC) loginRequest() error {
 
 	err = json.NewDecoder(resp.Body).Decode(&endpointResp)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	for _, v := range endpointResp.Token.Catalog {
 		if v.Type == "smn" {
 			for _, endpoint := range v.Endpoints {
-				c.OtcBaseURL = fmt.Sprintf("%s%s", endpoint.URL, c.ProjectID)
-				continue
+				baseURL = fmt.Sprintf("%s%s", endpoint.URL, c.ProjectID)
 			}
 		}
+	}
 
-		if c.OtcBaseURL != "" {
-			continue
-		}
+	if baseURL == "" {
+		return "", "", fmt.Errorf("unable to find snm endpoint")
 	}
+	return token, baseURL, nil
+}
 
-	if c.OtcBaseURL == "" {
-		return fmt.Errorf("unable to find snm endpoint")
+// HealthCheck acquires a new token from the identity endpoint. Sends keep
+// using the current token until the new one replaces it.
+func (c *OTC) HealthCheck() error {
+	token, baseURL, err := c.loginRequest(context.Background())
+	if err != nil {
+		return err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.baseURL = token, baseURL
 	return nil
 }
 
 func (c *OTC) SendRequest(method, resource string, payload *smsRequest, attempts int) (io.Reader, error) {
-	if len(c.Token) == 0 {
-		err := c.loginRequest()
-		if err != nil {
-			return nil, err
-		}
+	return c.sendRequest(context.Background(), method, resource, payload, attempts)
+}
+
+func (c *OTC) sendRequest(ctx context.Context, method, resource string, payload *smsRequest, attempts int) (io.Reader, error) {
+	token, baseURL, err := c.session(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/%s", c.OtcBaseURL, resource)
+	url := fmt.Sprintf("%s/%s", baseURL, resource)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Auth-Token", c.Token)
-
-	tr := http.DefaultTransport.(*http.Transport)
-	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: c.Insecure}
+	req.Header.Set("X-Auth-Token", token)
 
-	client := &http.Client{
-		Timeout:   10 * time.Second,
-		Transport: tr,
-	}
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		// Set empty token to force login.
-		c.Token = ""
+		// Forget the token to force login.
+		c.expire(token)
 		if attempts--; attempts > 0 {
-			return c.SendRequest(method, resource, payload, attempts)
+			return c.sendRequest(ctx, method, resource, payload, attempts)
 		}
-		return nil, err
+		return nil, fmt.Errorf("OTC API request %s failed with HTTP status code %d", url, resp.StatusCode)
 	} else if resp.StatusCode >= http.StatusBadRequest {
 		return nil, fmt.Errorf("OTC API request %s failed with HTTP status code %d", url, resp.StatusCode)
 	}
@@ -218,16 +268,26 @@ func (c *OTC) SendRequest(method, resource string, payload *smsRequest, attempts
 }
 
 // Send send sms to n number of people using bulk sms api.
-func (c *OTC) Send(message sachet.Message) (err error) {
+func (c *OTC) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *OTC) SendContext(ctx context.Context, message sachet.Message) error {
 	for _, recipent := range message.To {
 		r1 := &smsRequest{
 			Endpoint: recipent,
 			Message:  message.Text,
 		}
-		_, err := c.SendRequest("POST", "notifications/sms", r1, 2)
+		_, err := c.sendRequest(ctx, "POST", "notifications/sms", r1, 2)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// PhoneNumberFormat returns the format OTC expects recipient numbers in.
+func (c *OTC) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164
+}
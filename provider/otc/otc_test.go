@@ -0,0 +1,127 @@
+package otc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/messagebird/sachet"
+)
+
+// Sends and health checks share the token, run with -race to check that it
+// is renewed safely while messages are sent with it.
+func TestOTC_concurrentHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	var (
+		logins int64
+		issued sync.Map
+		server *httptest.Server
+	)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/tokens":
+			token := fmt.Sprintf("token-%d", atomic.AddInt64(&logins, 1))
+			issued.Store(token, true)
+			w.Header().Set("X-Subject-Token", token)
+			fmt.Fprintf(w, `{"token": {"catalog": [{"type": "smn", "endpoints": [{"url": "%s/v2/"}]}]}}`, server.URL)
+		case "/v2/project/notifications/sms":
+			if _, ok := issued.Load(r.Header.Get("X-Auth-Token")); !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewOTC(Config{IdentityEndpoint: server.URL + "/v3/auth/tokens", ProjectID: "project"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- c.HealthCheck()
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- c.SendContext(context.Background(), sachet.Message{To: []string{"+31612345678"}, Text: "test"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestOTC_slowHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	var (
+		logins  int64
+		release = make(chan struct{})
+		server  *httptest.Server
+	)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/tokens":
+			// Every login but the first one hangs until released.
+			if atomic.AddInt64(&logins, 1) > 1 {
+				<-release
+			}
+			w.Header().Set("X-Subject-Token", "token")
+			fmt.Fprintf(w, `{"token": {"catalog": [{"type": "smn", "endpoints": [{"url": "%s/v2/"}]}]}}`, server.URL)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewOTC(Config{IdentityEndpoint: server.URL + "/v3/auth/tokens", ProjectID: "project"})
+	message := sachet.Message{To: []string{"+31612345678"}, Text: "test"}
+	assert.NoError(t, c.SendContext(context.Background(), message))
+
+	go c.HealthCheck()
+	for atomic.LoadInt64(&logins) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Sends keep using the current token while the health check logs in.
+	sent := make(chan error, 1)
+	go func() { sent <- c.SendContext(context.Background(), message) }()
+	select {
+	case err := <-sent:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("send blocked by the health check")
+	}
+}
+
+func TestOTC_WrapTransport(t *testing.T) {
+	t.Parallel()
+
+	// The insecure transport stays under the wrapped one.
+	c := NewOTC(Config{Insecure: true})
+	var base http.RoundTripper
+	c.WrapTransport(func(t http.RoundTripper) http.RoundTripper {
+		base = t
+		return t
+	})
+	if assert.IsType(t, &http.Transport{}, base) {
+		assert.True(t, base.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	}
+}
@@ -9,10 +9,12 @@ import (
 )
 
 type Config struct {
-	Endpoint          string `yaml:"endpoint"`
-	ApplicationKey    string `yaml:"application_key"`
-	ApplicationSecret string `yaml:"application_secret"`
-	ConsumerKey       string `yaml:"consumer_key"`
+	Endpoint              string        `yaml:"endpoint"`
+	ApplicationKey        string        `yaml:"application_key"`
+	ApplicationSecret     sachet.Secret `yaml:"application_secret"`
+	ApplicationSecretFile string        `yaml:"application_secret_file"`
+	ConsumerKey           sachet.Secret `yaml:"consumer_key"`
+	ConsumerKeyFile       string        `yaml:"consumer_key_file"`
 
 	ServiceName       string `yaml:"service_name"`
 	SenderForResponse string `yaml:"sender_for_response"`
@@ -20,7 +22,7 @@ type Config struct {
 	Priority          string `yaml:"priority"`
 }
 
-var _ (sachet.Provider) = (*Ovh)(nil)
+var _ (sachet.CapableProvider) = (*Ovh)(nil)
 
 type Ovh struct {
 	client *ovh.Client
@@ -31,8 +33,8 @@ func NewOvh(config Config) (*Ovh, error) {
 	client, err := ovh.NewClient(
 		config.Endpoint,
 		config.ApplicationKey,
-		config.ApplicationSecret,
-		config.ConsumerKey,
+		string(config.ApplicationSecret),
+		string(config.ConsumerKey),
 	)
 	if err != nil {
 		return nil, err
@@ -43,6 +45,15 @@ func NewOvh(config Config) (*Ovh, error) {
 	}, nil
 }
 
+// Capabilities returns what the OVH provider supports.
+func (ovh *Ovh) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text"},
+		Batch:        true,
+		SenderID:     true,
+	}
+}
+
 func (ovh *Ovh) Send(message sachet.Message) error {
 	var err error = nil
 	switch message.Type {
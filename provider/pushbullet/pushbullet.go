@@ -16,10 +16,11 @@ const (
 
 // Config is the configuration struct for the Pushbullet provider.
 type Config struct {
-	AccessToken string `yaml:"access_token"`
+	AccessToken     sachet.Secret `yaml:"access_token"`
+	AccessTokenFile string        `yaml:"access_token_file"`
 }
 
-var _ (sachet.Provider) = (*Pushbullet)(nil)
+var _ (sachet.CapableProvider) = (*Pushbullet)(nil)
 
 // Pushbullet contains the necessary values for the Pushbullet provider.
 type Pushbullet struct {
@@ -31,19 +32,53 @@ func NewPushbullet(config Config) *Pushbullet {
 	return &Pushbullet{config}
 }
 
+// Capabilities returns what the Pushbullet provider supports. Recipients are
+// written as device:<nickname> or channel:<tag>, and the subject, or else the
+// sender, is used as the title of the note.
+func (c *Pushbullet) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes:      []string{"text"},
+		ValidateRecipient: validateRecipient,
+		SenderID:          true,
+		Subject:           true,
+	}
+}
+
+func validateRecipient(recipient string) error {
+	_, _, err := parseRecipient(recipient)
+	return err
+}
+
+// parseRecipient splits recipient into its target type and name.
+func parseRecipient(recipient string) (targetType, targetName string, err error) {
+	targetTypeName := strings.SplitN(recipient, ":", 2)
+	if len(targetTypeName) != 2 {
+		return "", "", fmt.Errorf("cannot parse recipient %s: expecting targetType:targetName", recipient)
+	}
+
+	switch targetTypeName[0] {
+	case deviceTargetType, channelTargetType:
+		return targetTypeName[0], targetTypeName[1], nil
+	}
+	return "", "", fmt.Errorf("unrecognised target type: %s", targetTypeName[0])
+}
+
 // Send pushes a note to devices registered in configuration.
 func (c *Pushbullet) Send(message sachet.Message) error {
+	title := message.Subject
+	if title == "" {
+		title = message.From
+	}
+
 	for _, recipient := range message.To {
 		// create pushbullet client.
-		pb := pushbullet.New(c.AccessToken)
+		pb := pushbullet.New(string(c.AccessToken))
 
 		// parse recipient.
-		targetTypeName := strings.SplitN(recipient, ":", 2)
-		if len(targetTypeName) != 2 {
-			return fmt.Errorf("cannot parse recipient %s: expecting targetType:targetName", recipient)
+		targetType, targetName, err := parseRecipient(recipient)
+		if err != nil {
+			return err
 		}
-		targetType := targetTypeName[0]
-		targetName := targetTypeName[1]
 
 		switch targetType {
 		case deviceTargetType:
@@ -54,7 +89,7 @@ func (c *Pushbullet) Send(message sachet.Message) error {
 			}
 
 			// push note
-			err = pb.PushNote(dev.Iden, message.From, message.Text)
+			err = pb.PushNote(dev.Iden, title, message.Text)
 			if err != nil {
 				return err
 			}
@@ -66,7 +101,7 @@ func (c *Pushbullet) Send(message sachet.Message) error {
 			}
 
 			// push note
-			err = sub.PushNote(message.From, message.Text)
+			err = sub.PushNote(title, message.Text)
 			if err != nil {
 				return err
 			}
@@ -1,21 +1,27 @@
 package sap
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 // Config is the configuration struct for Sap provider.
 type Config struct {
-	URL      string `yaml:"url"`
-	AuthHash string `yaml:"auth_hash"`
+	URL          string        `yaml:"url"`
+	AuthHash     sachet.Secret `yaml:"auth_hash"`
+	AuthHashFile string        `yaml:"auth_hash_file"`
 }
 
 var _ (sachet.Provider) = (*Sap)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Sap)(nil)
+var _ (sachet.ContextProvider) = (*Sap)(nil)
+var _ (sachet.HTTPProvider) = (*Sap)(nil)
 
 // Sap contains the necessary values for the Sap provider.
 type Sap struct {
@@ -34,18 +40,28 @@ func NewSap(config Config) *Sap {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to SAP.
+func (c *Sap) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
 // Send sends SMS to user registered in configuration.
 func (c *Sap) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Sap) SendContext(ctx context.Context, message sachet.Message) error {
 	// No \n in Text tolerated.
 	msg := strings.ReplaceAll(message.Text, "\n", " - ")
 	content := fmt.Sprintf("Version=2.0\nSubject=Alert\n[MSISDN]\nList=%s\n[MESSAGE]\nText=%s\n[SETUP]\nSplitText=yes\n[END]",
 		strings.Join(message.To, ","), msg)
 
-	request, err := http.NewRequest("POST", c.URL, strings.NewReader(content))
+	request, err := http.NewRequestWithContext(ctx, "POST", c.URL, strings.NewReader(content))
 	if err != nil {
 		return err
 	}
-	request.Header.Set("Authorization", "Basic "+c.AuthHash)
+	request.Header.Set("Authorization", "Basic "+string(c.AuthHash))
 
 	response, err := c.HTTPClient.Do(request)
 	if err != nil {
@@ -59,3 +75,8 @@ func (c *Sap) Send(message sachet.Message) error {
 
 	return fmt.Errorf("Failed sending sms. statusCode: %d", response.StatusCode)
 }
+
+// PhoneNumberFormat returns the format SAP expects recipient numbers in.
+func (c *Sap) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164
+}
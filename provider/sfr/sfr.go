@@ -1,6 +1,7 @@
 package sfr
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,16 +9,18 @@ import (
 	"time"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 // Config is the configuration struct for Sfr provider.
 type Config struct {
-	URL             string `yaml:"url"`
-	SPACEID         string `yaml:"space_id"`
-	SERVICEID       string `yaml:"service_id"`
-	SERVICEPASSWORD string `yaml:"service_password"`
-	LANG            string `yaml:"lang"`
-	TPOA            string `yaml:"tpoa"`
+	URL                 string        `yaml:"url"`
+	SPACEID             string        `yaml:"space_id"`
+	SERVICEID           string        `yaml:"service_id"`
+	SERVICEPASSWORD     sachet.Secret `yaml:"service_password"`
+	SERVICEPASSWORDFile string        `yaml:"service_password_file"`
+	LANG                string        `yaml:"lang"`
+	TPOA                string        `yaml:"tpoa"`
 }
 
 type Authenticate struct {
@@ -43,6 +46,10 @@ type ResponseBody struct {
 	Response      int64  `json:"response"`
 }
 
+var _ (sachet.PhoneNumberProvider) = (*Sfr)(nil)
+var _ (sachet.ContextProvider) = (*Sfr)(nil)
+var _ (sachet.HTTPProvider) = (*Sfr)(nil)
+
 // Sap contains the necessary values for the Sfr provider.
 type Sfr struct {
 	Config
@@ -60,21 +67,31 @@ func NewSfr(config Config) *Sfr {
 	}
 }
 
+// WrapTransport wraps the transport of the requests to SFR.
+func (c *Sfr) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
 // Send sends SMS to user registered in configuration.
 func (c *Sfr) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Sfr) SendContext(ctx context.Context, message sachet.Message) error {
 	// No \n in Text tolerated.
 	msg := strings.ReplaceAll(message.Text, "\n", " - ")
 
 	errors := 0
 	for _, dest := range message.To {
-		request, err := http.NewRequest("GET", c.URL, nil)
+		request, err := http.NewRequestWithContext(ctx, "GET", c.URL, nil)
 		if err != nil {
 			return err
 		}
 
 		authenticate := &Authenticate{
 			ServiceId:       c.SERVICEID,
-			ServicePassword: c.SERVICEPASSWORD,
+			ServicePassword: string(c.SERVICEPASSWORD),
 			SpaceId:         c.SPACEID,
 			Lang:            c.LANG,
 		}
@@ -131,3 +148,8 @@ func (c *Sfr) Send(message sachet.Message) error {
 	}
 	return nil
 }
+
+// PhoneNumberFormat returns the format SFR expects recipient numbers in.
+func (c *Sfr) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164
+}
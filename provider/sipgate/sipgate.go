@@ -2,6 +2,7 @@ package sipgate
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,24 +13,34 @@ import (
 
 const sipgateURL = "https://api.sipgate.com/v2/sessions/sms"
 
-var sipgateHTTPClient = &http.Client{Timeout: time.Second * 20}
-
 // Config is the configuration struct for Sipgate provider.
 type Config struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Username     string        `yaml:"username"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
 }
 
 var _ (sachet.Provider) = (*Sipgate)(nil)
+var _ (sachet.ContextProvider) = (*Sipgate)(nil)
+var _ (sachet.HTTPProvider) = (*Sipgate)(nil)
 
 // Sipgate contains the necessary values for the Sipgate provider.
 type Sipgate struct {
 	Config
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 // NewSipgate creates and returns a new Sipgate struct.
 func NewSipgate(config Config) *Sipgate {
-	return &Sipgate{config}
+	return &Sipgate{
+		config,
+		&http.Client{Timeout: time.Second * 20},
+	}
+}
+
+// WrapTransport wraps the transport of the requests to Sipgate.
+func (c *Sipgate) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
 }
 
 type payload struct {
@@ -40,6 +51,11 @@ type payload struct {
 
 // Send sends SMS to user registered in configuration.
 func (c *Sipgate) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Sipgate) SendContext(ctx context.Context, message sachet.Message) error {
 	for _, recipient := range message.To {
 		params := payload{
 			SmsID:     message.From,
@@ -52,16 +68,16 @@ func (c *Sipgate) Send(message sachet.Message) error {
 			return err
 		}
 
-		request, err := http.NewRequest("POST", sipgateURL, bytes.NewBuffer(data))
+		request, err := http.NewRequestWithContext(ctx, "POST", sipgateURL, bytes.NewBuffer(data))
 		if err != nil {
 			return err
 		}
 
-		request.SetBasicAuth(c.Username, c.Password)
+		request.SetBasicAuth(c.Username, string(c.Password))
 		request.Header.Set("Content-Type", "application/json")
 		request.Header.Set("User-Agent", "Sachet")
 
-		response, err := sipgateHTTPClient.Do(request)
+		response, err := c.HTTPClient.Do(request)
 		if err != nil {
 			return err
 		}
@@ -11,11 +11,15 @@ import (
 
 // Config is the configuration struct for Sms77 provider.
 type Config struct {
-	ApiKey string `yaml:"api_key"`
-	Debug  bool   `yaml:"debug"`
+	ApiKey     sachet.Secret `yaml:"api_key"`
+	ApiKeyFile string        `yaml:"api_key_file"`
+
+	// Deprecated: Debug is ignored. The sms77 client does not let Sachet log
+	// its requests, as it does for other providers with -log-level=debug.
+	Debug bool `yaml:"debug,omitempty"`
 }
 
-var _ (sachet.Provider) = (*Sms77)(nil)
+var _ (sachet.CapableProvider) = (*Sms77)(nil)
 
 // Sms77 contains the necessary values for the Sms77 provider.
 type Sms77 struct {
@@ -26,8 +30,7 @@ type Sms77 struct {
 // NewSms77 creates and returns a new Sms77 struct.
 func NewSms77(config Config) *Sms77 {
 	client := sms77api.New(sms77api.Options{
-		ApiKey:   config.ApiKey,
-		Debug:    config.Debug,
+		ApiKey:   string(config.ApiKey),
 		SentWith: "Sachet",
 	})
 
@@ -37,6 +40,15 @@ func NewSms77(config Config) *Sms77 {
 	}
 }
 
+// Capabilities returns what the Sms77 provider supports.
+func (s77 *Sms77) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text", "voice"},
+		Batch:        true,
+		SenderID:     true,
+	}
+}
+
 // Send sends SMS to user registered in configuration.
 func (s77 *Sms77) Send(message sachet.Message) error {
 	var err error = nil
@@ -1,6 +1,7 @@
 package smsc
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,27 +11,45 @@ import (
 )
 
 type Config struct {
-	Login    string `yaml:"login"`
-	Password string `yaml:"password"`
+	Login        string        `yaml:"login"`
+	Password     sachet.Secret `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
 }
 
 const SmscRequestTimeout = time.Second * 60
 
 var _ (sachet.Provider) = (*Smsc)(nil)
+var _ (sachet.ContextProvider) = (*Smsc)(nil)
+var _ (sachet.HTTPProvider) = (*Smsc)(nil)
 
 type Smsc struct {
-	Login    string
-	Password string
+	Login      string
+	Password   string
+	HTTPClient *http.Client // The HTTP client to send requests on.
 }
 
 func NewSmsc(config Config) *Smsc {
-	Smsc := &Smsc{Login: config.Login, Password: config.Password}
+	Smsc := &Smsc{
+		Login:      config.Login,
+		Password:   string(config.Password),
+		HTTPClient: &http.Client{Timeout: SmscRequestTimeout},
+	}
 	return Smsc
 }
 
-func (c *Smsc) Send(message sachet.Message) (err error) {
+// WrapTransport wraps the transport of the requests to SMSC.
+func (c *Smsc) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.HTTPClient.Transport = wrap(sachet.TransportOrDefault(c.HTTPClient.Transport))
+}
+
+func (c *Smsc) Send(message sachet.Message) error {
+	return c.SendContext(context.Background(), message)
+}
+
+// SendContext sends message, making the requests with ctx.
+func (c *Smsc) SendContext(ctx context.Context, message sachet.Message) (err error) {
 	for _, number := range message.To {
-		err = c.SendOne(message, number)
+		err = c.SendOne(ctx, message, number)
 		if err != nil {
 			return fmt.Errorf("Failed to make API call to smsc: %w", err)
 		}
@@ -38,19 +57,17 @@ func (c *Smsc) Send(message sachet.Message) (err error) {
 	return
 }
 
-func (c *Smsc) SendOne(message sachet.Message, phoneNumber string) (err error) {
+func (c *Smsc) SendOne(ctx context.Context, message sachet.Message, phoneNumber string) (err error) {
 	encodedMessage := url.QueryEscape(message.Text)
 	smsURL := fmt.Sprintf("https://smsc.ru/sys/send.php?login=%s&psw=%s&phones=%s&sender=%s&fmt=0&mes=%s",
 		c.Login, c.Password, phoneNumber, message.From, encodedMessage)
 	var request *http.Request
 	var resp *http.Response
-	request, err = http.NewRequest("GET", smsURL, nil)
+	request, err = http.NewRequestWithContext(ctx, "GET", smsURL, nil)
 	if err != nil {
 		return
 	}
-	httpClient := &http.Client{}
-	httpClient.Timeout = SmscRequestTimeout
-	resp, err = httpClient.Do(request)
+	resp, err = c.HTTPClient.Do(request)
 	if err != nil {
 		return
 	}
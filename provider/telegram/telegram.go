@@ -1,7 +1,10 @@
 package telegram
 
 import (
+	"fmt"
+	"net/http"
 	"strconv"
+	"time"
 
 	tgbotapi "gopkg.in/telegram-bot-api.v4"
 
@@ -9,33 +12,76 @@ import (
 )
 
 type Config struct {
-	Token                 string `yaml:"token"`
-	ParseMode             string `yaml:"parse_mode"`
-	DisableWebPagePreview bool   `yaml:"disable_web_page_preview"`
+	Token                 sachet.Secret `yaml:"token"`
+	TokenFile             string        `yaml:"token_file"`
+	ParseMode             string        `yaml:"parse_mode"`
+	DisableWebPagePreview bool          `yaml:"disable_web_page_preview"`
 }
 
-var _ (sachet.Provider) = (*Telegram)(nil)
+// maxTextLength is the maximum length of a Telegram message.
+const maxTextLength = 4096
+
+var _ (sachet.CapableProvider) = (*Telegram)(nil)
+var _ (sachet.HealthChecker) = (*Telegram)(nil)
+var _ (sachet.HTTPProvider) = (*Telegram)(nil)
 
 type Telegram struct {
 	bot    *tgbotapi.BotAPI
 	config *Config
 }
 
-func NewTelegram(config Config) (*Telegram, error) {
-	bot, err := tgbotapi.NewBotAPI(config.Token)
-	if err != nil {
-		return nil, err
+// NewTelegram creates a Telegram provider. Unlike tgbotapi.NewBotAPI it does
+// not contact Telegram, so it can be used while loading the configuration.
+func NewTelegram(config Config) *Telegram {
+	bot := &tgbotapi.BotAPI{
+		Token:  string(config.Token),
+		Client: &http.Client{Timeout: time.Second * 20},
+		Buffer: 100,
 	}
 
 	return &Telegram{
 		bot:    bot,
 		config: &config,
-	}, nil
+	}
+}
+
+// WrapTransport wraps the transport of the requests to Telegram.
+func (tg *Telegram) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	tg.bot.Client.Transport = wrap(sachet.TransportOrDefault(tg.bot.Client.Transport))
+}
+
+// Capabilities returns what the Telegram provider supports. Recipients are
+// numeric chat IDs.
+func (tg *Telegram) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes:      []string{"text"},
+		ValidateRecipient: validateChatID,
+		MaxTextLength:     maxTextLength,
+	}
+}
+
+func validateChatID(recipient string) error {
+	_, err := parseChatID(recipient)
+	return err
+}
+
+func parseChatID(recipient string) (int64, error) {
+	chatID, err := strconv.ParseInt(recipient, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("chat ID must be numeric")
+	}
+	return chatID, nil
+}
+
+// HealthCheck calls getMe, which fails if the bot token is not valid.
+func (tg *Telegram) HealthCheck() error {
+	_, err := tg.bot.GetMe()
+	return err
 }
 
 func (tg *Telegram) Send(message sachet.Message) error {
 	for _, sChatID := range message.To {
-		chatID, err := strconv.ParseInt(sChatID, 10, 64)
+		chatID, err := parseChatID(sChatID)
 		if err != nil {
 			return err
 		}
@@ -14,17 +14,18 @@ import (
 )
 
 type Config struct {
-	SecretId     string `yaml:"secret_id"`
-	SecretKey    string `yaml:"secret_key"`
-	AppId        string `yaml:"app_id"`
-	Region       string `yaml:"region"`
-	Endpoint     string `yaml:"endpoint"`
-	SignName     string `yaml:"sign_name"`
-	TemplateCode string `yaml:"template_code"`
-	Truncate     bool   `yaml:"truncate"`
+	SecretId      string        `yaml:"secret_id"`
+	SecretKey     sachet.Secret `yaml:"secret_key"`
+	SecretKeyFile string        `yaml:"secret_key_file"`
+	AppId         string        `yaml:"app_id"`
+	Region        string        `yaml:"region"`
+	Endpoint      string        `yaml:"endpoint"`
+	SignName      string        `yaml:"sign_name"`
+	TemplateCode  string        `yaml:"template_code"`
+	Truncate      bool          `yaml:"truncate"`
 }
 
-var _ (sachet.Provider) = (*TencentCloud)(nil)
+var _ (sachet.CapableProvider) = (*TencentCloud)(nil)
 
 type TencentCloud struct {
 	client *sms.Client
@@ -34,7 +35,7 @@ type TencentCloud struct {
 func NewTencentCloud(config Config) *TencentCloud {
 	credential := common.NewCredential(
 		config.SecretId,
-		config.SecretKey,
+		string(config.SecretKey),
 	)
 	cpf := profile.NewClientProfile()
 	cpf.HttpProfile.ReqMethod = "POST"
@@ -58,6 +59,15 @@ func truncateString(str string, num int) string {
 	return bnoden
 }
 
+// Capabilities returns what the TencentCloud provider supports.
+// The sender is configured with sign_name instead of Message.From.
+func (tencentcloud *TencentCloud) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text"},
+		Batch:        true,
+	}
+}
+
 func (tencentcloud *TencentCloud) Send(message sachet.Message) error {
 	switch message.Type {
 	case "", "text":
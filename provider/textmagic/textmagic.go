@@ -11,11 +11,12 @@ import (
 )
 
 type Config struct {
-	Username string `yaml:"username"`
-	APIKey   string `yaml:"api_key"`
+	Username   string        `yaml:"username"`
+	APIKey     sachet.Secret `yaml:"api_key"`
+	APIKeyFile string        `yaml:"api_key_file"`
 }
 
-var _ (sachet.Provider) = (*TextMagic)(nil)
+var _ (sachet.CapableProvider) = (*TextMagic)(nil)
 
 type TextMagic struct {
 	client *textmagic.APIClient
@@ -28,7 +29,7 @@ func NewTextMagic(config Config) *TextMagic {
 	client := textmagic.NewAPIClient(cfg)
 	auth := context.WithValue(context.Background(), textmagic.ContextBasicAuth, textmagic.BasicAuth{
 		UserName: config.Username,
-		Password: config.APIKey,
+		Password: string(config.APIKey),
 	})
 	return &TextMagic{
 		client: client,
@@ -36,6 +37,15 @@ func NewTextMagic(config Config) *TextMagic {
 	}
 }
 
+// Capabilities returns what the TextMagic provider supports.
+func (tm *TextMagic) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes: []string{"text"},
+		Batch:        true,
+		SenderID:     true,
+	}
+}
+
 func (tm *TextMagic) Send(message sachet.Message) (err error) {
 	switch message.Type {
 	case "", "text":
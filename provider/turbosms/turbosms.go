@@ -15,22 +15,32 @@ import (
 
 // sachet section.
 type Config struct {
-	Alogin    string `yaml:"login"`
-	Apassword string `yaml:"password"`
+	Alogin        string        `yaml:"login"`
+	Apassword     sachet.Secret `yaml:"password"`
+	ApasswordFile string        `yaml:"password_file"`
 }
 
 var _ (sachet.Provider) = (*Turbosms)(nil)
+var _ (sachet.HTTPProvider) = (*Turbosms)(nil)
 
 type Turbosms struct {
 	Login    string
 	Password string
+	// Transport sends the requests of the clients made for every message,
+	// which keep the session cookie.
+	Transport http.RoundTripper
 }
 
 func NewTurbosms(config Config) *Turbosms {
-	Turbosms := &Turbosms{Login: config.Alogin, Password: config.Apassword}
+	Turbosms := &Turbosms{Login: config.Alogin, Password: string(config.Apassword)}
 	return Turbosms
 }
 
+// WrapTransport wraps the transport of the requests to TurboSMS.
+func (c *Turbosms) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.Transport = wrap(sachet.TransportOrDefault(c.Transport))
+}
+
 // http  url for  turbosms.
 var urlSoap string = "http://turbosms.in.ua/api/soap.html"
 
@@ -119,8 +129,9 @@ func (c *Turbosms) Send(message sachet.Message) (err error) {
 	}
 	cookieJar, _ := cookiejar.New(nil)
 	clientConfig := &http.Client{
-		Timeout: 15 * time.Second,
-		Jar:     cookieJar,
+		Transport: c.Transport,
+		Timeout:   15 * time.Second,
+		Jar:       cookieJar,
 	}
 	reply, err, statusreply := Request(clientConfig, urlSoap, data)
 	if err != nil {
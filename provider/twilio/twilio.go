@@ -1,33 +1,117 @@
 package twilio
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/carlosdp/twiliogo"
 
 	"github.com/messagebird/sachet"
+	"github.com/messagebird/sachet/phonenumber"
 )
 
 type Config struct {
-	AccountSID string `yaml:"account_sid"`
-	AuthToken  string `yaml:"auth_token"`
+	AccountSID    string        `yaml:"account_sid"`
+	AuthToken     sachet.Secret `yaml:"auth_token"`
+	AuthTokenFile string        `yaml:"auth_token_file"`
 }
 
 var _ (sachet.Provider) = (*Twilio)(nil)
+var _ (sachet.PhoneNumberProvider) = (*Twilio)(nil)
+var _ (sachet.CapableProvider) = (*Twilio)(nil)
+var _ (sachet.HealthChecker) = (*Twilio)(nil)
+var _ (sachet.ResultProvider) = (*Twilio)(nil)
 
 type Twilio struct {
 	client twiliogo.Client
 }
 
 func NewTwilio(config Config) *Twilio {
-	return &Twilio{client: twiliogo.NewClient(config.AccountSID, config.AuthToken)}
+	return &Twilio{client: twiliogo.NewClient(config.AccountSID, string(config.AuthToken))}
+}
+
+// Capabilities returns what the Twilio provider supports.
+func (tw *Twilio) Capabilities() sachet.Capabilities {
+	return sachet.Capabilities{
+		MessageTypes:  []string{"text"},
+		MaxTextLength: 1600,
+		SenderID:      true,
+	}
+}
+
+// HealthCheck fetches the Twilio account, which fails if the credentials are
+// wrong, and checks that it is active.
+func (tw *Twilio) HealthCheck() error {
+	request, err := http.NewRequest(http.MethodGet, tw.client.RootUrl()+".json", nil)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(tw.client.AccountSid(), tw.client.AuthToken())
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching account failed with status code %d", response.StatusCode)
+	}
+
+	var account struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&account); err != nil {
+		return err
+	}
+	if account.Status != "active" {
+		return fmt.Errorf("account is %s", account.Status)
+	}
+	return nil
 }
 
+// Send sends message to every recipient, and fails if it could not be sent
+// to any of them.
 func (tw *Twilio) Send(message sachet.Message) error {
-	for _, recipient := range message.To {
-		_, err := twiliogo.NewMessage(tw.client, message.From, recipient, twiliogo.Body(message.Text))
+	results, err := tw.SendResults(context.Background(), message)
+	if err != nil {
+		return err
+	}
+	return sachet.ResultsError(results)
+}
+
+// SendResults sends message to every recipient in turn, carrying on after a
+// recipient fails, and returns the SID Twilio gave each message. The Twilio
+// client does not take a context, so ctx is checked between recipients.
+func (tw *Twilio) SendResults(ctx context.Context, message sachet.Message) ([]sachet.Result, error) {
+	results := make([]sachet.Result, len(message.To))
+	for i, recipient := range message.To {
+		results[i].Recipient = recipient
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		msg, err := twiliogo.NewMessage(tw.client, message.From, recipient, twiliogo.Body(message.Text))
 		if err != nil {
-			return err
+			// Invalid recipients and messages are refused with 400.
+			var twilioErr *twiliogo.TwilioError
+			if errors.As(err, &twilioErr) && twilioErr.Status == http.StatusBadRequest {
+				err = sachet.Rejected(err)
+			}
+			results[i].Err = err
+			continue
 		}
+		results[i].MessageID = msg.Sid
 	}
+	return results, nil
+}
 
-	return nil
+// PhoneNumberFormat returns the format Twilio expects recipient numbers in.
+func (tw *Twilio) PhoneNumberFormat() phonenumber.Format {
+	return phonenumber.E164
 }
@@ -1,12 +1,209 @@
 package sachet
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/messagebird/sachet/phonenumber"
+)
+
+// Provider sends messages through a gateway. A provider is created once per
+// configuration and shared by every request, so Send must be safe for
+// concurrent use.
 type Provider interface {
 	Send(message Message) error
 }
 
+// ContextProvider is implemented by providers that make their requests with
+// a context, so that sends can be cancelled and traced.
+type ContextProvider interface {
+	Provider
+	SendContext(ctx context.Context, message Message) error
+}
+
+// ResultProvider is implemented by providers that report the outcome of a
+// send for every recipient, and the ID the gateway gave the message.
+type ResultProvider interface {
+	Provider
+	// SendResults sends message, stopping when ctx is done, and returns a
+	// result for every recipient. An error means that nothing was sent.
+	SendResults(ctx context.Context, message Message) ([]Result, error)
+}
+
+// PhoneNumberProvider is implemented by providers that address recipients by
+// phone number. Recipients of receivers using such a provider are validated
+// when the configuration is loaded and rewritten in the returned format.
+type PhoneNumberProvider interface {
+	Provider
+	PhoneNumberFormat() phonenumber.Format
+}
+
+// CapableProvider is implemented by providers that declare what they support,
+// so that receivers can be validated when the configuration is loaded rather
+// than when Send fails at alert time.
+type CapableProvider interface {
+	Provider
+	Capabilities() Capabilities
+}
+
+// HealthChecker is implemented by providers that can check, without sending
+// a message, that they are able to send: for example that the gateway is
+// reachable and accepts their credentials.
+type HealthChecker interface {
+	Provider
+	HealthCheck() error
+}
+
+// HTTPProvider is implemented by providers that send their requests with an
+// HTTP client of their own, so that the application can log and trace them.
+type HTTPProvider interface {
+	Provider
+	// WrapTransport replaces the transport of the provider's client with
+	// wrap applied to it. It is called before the provider is used.
+	WrapTransport(wrap func(http.RoundTripper) http.RoundTripper)
+}
+
+// TransportOrDefault returns t, or http.DefaultTransport, which clients use
+// when their transport is nil, if t is nil.
+func TransportOrDefault(t http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
+
+// Capabilities describes the messages a provider is able to send.
+type Capabilities struct {
+	// MessageTypes lists the supported Message.Type values besides the empty
+	// type, which always selects the provider's default.
+	MessageTypes []string
+	// ValidateRecipient returns an error for recipients the provider cannot
+	// address. A nil function accepts every recipient.
+	ValidateRecipient func(recipient string) error
+	// MaxTextLength is the maximum length of Message.Text in characters.
+	// Longer texts are truncated before sending. Zero means unlimited.
+	MaxTextLength int
+	// Batch is true if all recipients are sent in a single request.
+	Batch bool
+	// SenderID is true if the provider uses Message.From.
+	SenderID bool
+	// Subject is true if the provider uses Message.Subject.
+	Subject bool
+}
+
+// SupportsType reports whether messages of type t can be sent.
+func (c Capabilities) SupportsType(t string) bool {
+	if t == "" {
+		return true
+	}
+	for _, mt := range c.MessageTypes {
+		if mt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the type, sender and recipients of message against c. The
+// text is not validated as it is usually rendered at send time.
+func (c Capabilities) Validate(message Message) error {
+	if !c.SupportsType(message.Type) {
+		return fmt.Errorf("unsupported message type %q, expected one of: %s", message.Type, strings.Join(c.MessageTypes, ", "))
+	}
+	if message.From != "" && !c.SenderID {
+		return fmt.Errorf("sender ID %q is not supported", message.From)
+	}
+	if message.Subject != "" && !c.Subject {
+		return errors.New("subject is not supported")
+	}
+	if c.ValidateRecipient != nil {
+		for _, recipient := range message.To {
+			if err := c.ValidateRecipient(recipient); err != nil {
+				return fmt.Errorf("invalid recipient %q: %w", recipient, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Truncate shortens text to MaxTextLength characters.
+func (c Capabilities) Truncate(text string) string {
+	if c.MaxTextLength <= 0 {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= c.MaxTextLength {
+		return text
+	}
+	return string(runes[:c.MaxTextLength])
+}
+
 type Message struct {
 	To   []string
 	From string
 	Text string
 	Type string
+	// Subject is the title of the message, for providers that show one.
+	Subject string
+}
+
+// Result is the outcome of sending a message to one recipient.
+type Result struct {
+	Recipient string
+	// MessageID is the ID the gateway gave the message, if it returns one.
+	MessageID string
+	// Err is the reason the message was not sent to Recipient, or nil.
+	Err error
+}
+
+// RejectedError is a failure to send caused by the message or its recipient,
+// such as an invalid phone number, rather than by the provider. The gateway
+// answered, so it does not count against the provider's circuit breaker.
+type RejectedError struct {
+	Err error
+}
+
+func (e *RejectedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Rejected marks err as caused by the message or its recipient. It returns
+// nil if err is nil.
+func Rejected(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RejectedError{Err: err}
+}
+
+// IsRejected reports whether err was marked with Rejected.
+func IsRejected(err error) bool {
+	var rejected *RejectedError
+	return errors.As(err, &rejected)
+}
+
+// ResultsError returns nil if every result succeeded, and otherwise an error
+// counting the failed recipients and giving the reason of the first one.
+func ResultsError(results []Result) error {
+	var first error
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			if first == nil {
+				first = r.Err
+			}
+			failed++
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return fmt.Errorf("sending to %d of %d recipients failed: %w", failed, len(results), first)
 }
@@ -0,0 +1,206 @@
+package sachet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCapabilities_Validate(t *testing.T) {
+	t.Parallel()
+
+	caps := Capabilities{
+		MessageTypes: []string{"text", "voice"},
+		ValidateRecipient: func(recipient string) error {
+			if !strings.HasPrefix(recipient, "chat:") {
+				return errors.New("expecting chat:<id>")
+			}
+			return nil
+		},
+	}
+
+	cases := []struct {
+		name    string
+		caps    Capabilities
+		message Message
+		err     string
+	}{
+		{
+			name:    "default type",
+			caps:    caps,
+			message: Message{To: []string{"chat:1"}},
+		},
+		{
+			name:    "supported type",
+			caps:    caps,
+			message: Message{To: []string{"chat:1"}, Type: "voice"},
+		},
+		{
+			name:    "unsupported type",
+			caps:    caps,
+			message: Message{To: []string{"chat:1"}, Type: "fax"},
+			err:     `unsupported message type "fax", expected one of: text, voice`,
+		},
+		{
+			name:    "invalid recipient",
+			caps:    caps,
+			message: Message{To: []string{"chat:1", "1234"}},
+			err:     `invalid recipient "1234": expecting chat:<id>`,
+		},
+		{
+			name:    "sender ID not supported",
+			caps:    caps,
+			message: Message{To: []string{"chat:1"}, From: "sachet"},
+			err:     `sender ID "sachet" is not supported`,
+		},
+		{
+			name:    "sender ID supported",
+			caps:    Capabilities{SenderID: true},
+			message: Message{To: []string{"1234"}, From: "sachet"},
+		},
+		{
+			name:    "subject not supported",
+			caps:    caps,
+			message: Message{To: []string{"chat:1"}, Subject: "Alert"},
+			err:     "subject is not supported",
+		},
+		{
+			name:    "subject supported",
+			caps:    Capabilities{Subject: true},
+			message: Message{To: []string{"1234"}, Subject: "Alert"},
+		},
+	}
+	for _, tc := range cases {
+		err := tc.caps.Validate(tc.message)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.name)
+		}
+	}
+}
+
+func TestCapabilities_Truncate(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "héllo wörld", Capabilities{}.Truncate("héllo wörld"))
+	assert.Equal(t, "héllo", Capabilities{MaxTextLength: 5}.Truncate("héllo wörld"))
+	assert.Equal(t, "héllo", Capabilities{MaxTextLength: 5}.Truncate("héllo"))
+}
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+
+	config := struct {
+		Token Secret `yaml:"token" json:"token"`
+		Empty Secret `yaml:"empty" json:"empty"`
+	}{Token: "hunter2"}
+
+	y, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "token: <secret>\nempty: \"\"\n", string(y))
+
+	j, err := json.Marshal(config)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"token":"\u003csecret\u003e","empty":""}`, string(j))
+
+	assert.Equal(t, "<secret> <secret> {Token:<secret> Empty:}", fmt.Sprintf("%s %v %+v", config.Token, config.Token, config))
+	assert.Equal(t, "hunter2", string(config.Token))
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("SACHET_TEST_TOKEN", "from-env")
+	t.Setenv("SACHET_TEST_DIR", t.TempDir())
+	filename := filepath.Join(os.Getenv("SACHET_TEST_DIR"), "password")
+	if err := ioutil.WriteFile(filename, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type provider struct {
+		Token        Secret `yaml:"token"`
+		Password     Secret `yaml:"password"`
+		PasswordFile string `yaml:"password_file"`
+	}
+	type providers struct {
+		Example provider `yaml:"example"`
+	}
+
+	cases := []struct {
+		name string
+		in   provider
+		exp  provider
+		err  string
+	}{
+		{
+			name: "inline",
+			in:   provider{Token: "token", Password: "password"},
+			exp:  provider{Token: "token", Password: "password"},
+		},
+		{
+			name: "environment",
+			in:   provider{Token: "${SACHET_TEST_TOKEN}", Password: "pre-${SACHET_TEST_TOKEN}-$HOME"},
+			exp:  provider{Token: "from-env", Password: "pre-from-env-$HOME"},
+		},
+		{
+			name: "escaped",
+			in:   provider{Token: "$${SACHET_TEST_TOKEN}", Password: "p$${SACHET_TEST_UNSET}-$$${SACHET_TEST_TOKEN}"},
+			exp:  provider{Token: "${SACHET_TEST_TOKEN}", Password: "p${SACHET_TEST_UNSET}-$${SACHET_TEST_TOKEN}"},
+		},
+		{
+			name: "file",
+			in:   provider{PasswordFile: "${SACHET_TEST_DIR}/password"},
+			exp:  provider{Password: "from-file", PasswordFile: "${SACHET_TEST_DIR}/password"},
+		},
+		{
+			name: "unset variable",
+			in:   provider{Token: "${SACHET_TEST_UNSET}"},
+			err:  "example.token: environment variable SACHET_TEST_UNSET is not set",
+		},
+		{
+			name: "both set",
+			in:   provider{Password: "password", PasswordFile: filename},
+			err:  "example.password_file: cannot be set together with password",
+		},
+		{
+			name: "missing file",
+			in:   provider{PasswordFile: filename + ".missing"},
+			err:  "example.password_file: open " + filename + ".missing: no such file or directory",
+		},
+	}
+	for _, tc := range cases {
+		config := providers{Example: tc.in}
+		err := ResolveSecrets(&config)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.exp, config.Example, tc.name)
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	t.Setenv("SACHET_TEST_DIR", "/run/secrets")
+
+	type provider struct {
+		Token        Secret `yaml:"token"`
+		Password     Secret `yaml:"password"`
+		PasswordFile string `yaml:"password_file"`
+	}
+	config := struct {
+		Example provider `yaml:"example"`
+		Other   provider `yaml:"other"`
+	}{
+		Example: provider{PasswordFile: "${SACHET_TEST_DIR}/password"},
+		Other:   provider{Password: "password"},
+	}
+	assert.Equal(t, []string{"/run/secrets/password"}, SecretFiles(&config))
+}
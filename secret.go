@@ -0,0 +1,197 @@
+package sachet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// secretToken replaces the value of secrets when they are marshalled or printed.
+const secretToken = "<secret>"
+
+// Secret is a string holding a credential. It redacts itself when marshalled
+// to YAML or JSON and when formatted, so that configurations can be printed
+// and logged without leaking it. Convert it with string() to use the value.
+// ResolveSecrets expands the ${NAME} references in it, so a credential that
+// contains ${NAME} literally is written $${NAME}.
+type Secret string
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	if s == "" {
+		return "", nil
+	}
+	return secretToken, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return []byte(`""`), nil
+	}
+	return []byte(`"` + secretToken + `"`), nil
+}
+
+// String implements fmt.Stringer.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secretToken
+}
+
+// GoString implements fmt.GoStringer, which is used for %#v.
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// envReference matches the ${NAME} references expanded in secrets, and the
+// $${NAME} escapes written for them to be kept literally.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ResolveSecrets fills in the Secret fields of the struct v points to, and of
+// the structs nested in it. For a Secret field X, a non-empty string field
+// XFile names a file the secret is read from, with trailing newlines
+// removed. ${NAME} references to environment variables are expanded in
+// inline secrets and file names, but not in file contents, and $${NAME} is
+// replaced with a literal ${NAME}.
+func ResolveSecrets(v interface{}) error {
+	return resolveSecrets(reflect.ValueOf(v).Elem())
+}
+
+func resolveSecrets(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := resolveSecrets(v.Field(i)); err != nil {
+				return fmt.Errorf("%s.%w", yamlName(field), err)
+			}
+			continue
+		}
+		if field.Type != reflect.TypeOf(Secret("")) {
+			continue
+		}
+
+		key := yamlName(field)
+		secret, err := expandEnv(v.Field(i).String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if fileField, ok := t.FieldByName(field.Name + "File"); ok && fileField.Type.Kind() == reflect.String {
+			fileKey := yamlName(fileField)
+			filename, err := expandEnv(v.FieldByIndex(fileField.Index).String())
+			if err != nil {
+				return fmt.Errorf("%s: %w", fileKey, err)
+			}
+			if filename != "" {
+				if secret != "" {
+					return fmt.Errorf("%s: cannot be set together with %s", fileKey, key)
+				}
+				content, err := ioutil.ReadFile(filename)
+				if err != nil {
+					return fmt.Errorf("%s: %w", fileKey, err)
+				}
+				secret = strings.TrimRight(string(content), "\r\n")
+			}
+		}
+
+		v.Field(i).SetString(secret)
+	}
+	return nil
+}
+
+// SecretValues returns the non-empty values of the Secret fields of the
+// struct v points to, and of the structs nested in it, so that they can be
+// redacted from logs.
+func SecretValues(v interface{}) []string {
+	return secretValues(reflect.ValueOf(v).Elem(), nil)
+}
+
+func secretValues(v reflect.Value, values []string) []string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			values = secretValues(v.Field(i), values)
+		case field.Type == reflect.TypeOf(Secret("")):
+			if s := v.Field(i).String(); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// SecretFiles returns the files the Secret fields of the struct v points to,
+// and of the structs nested in it, are read from, so that they can be
+// watched for changes.
+func SecretFiles(v interface{}) []string {
+	return secretFiles(reflect.ValueOf(v).Elem(), nil)
+}
+
+func secretFiles(v reflect.Value, files []string) []string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			files = secretFiles(v.Field(i), files)
+		case field.Type == reflect.TypeOf(Secret("")):
+			fileField, ok := t.FieldByName(field.Name + "File")
+			if !ok || fileField.Type.Kind() != reflect.String {
+				continue
+			}
+			// Resolving the secrets failed for unset variables already.
+			if filename, _ := expandEnv(v.FieldByIndex(fileField.Index).String()); filename != "" {
+				files = append(files, filename)
+			}
+		}
+	}
+	return files
+}
+
+// expandEnv replaces the ${NAME} references in s with the value of the
+// environment variable NAME, and the $${NAME} escapes with ${NAME}.
+// Referencing an unset variable is an error, so that a typo does not silently
+// leave a credential empty.
+func expandEnv(s string) (string, error) {
+	var err error
+	expanded := envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := envReference.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// yamlName returns the YAML key of field, for error messages.
+func yamlName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
@@ -0,0 +1,150 @@
+// Package sms measures how text is split into SMS segments.
+package sms
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding is the character set a text is sent in.
+type Encoding string
+
+const (
+	// GSM7 is the GSM 03.38 default alphabet, used when every character of
+	// the text is part of it.
+	GSM7 Encoding = "GSM-7"
+	// UCS2 is used for texts with characters outside of the GSM alphabet.
+	UCS2 Encoding = "UCS-2"
+)
+
+const (
+	gsm7SingleSegment = 160
+	gsm7MultiSegment  = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// gsm7Basic is the GSM 03.38 basic character set, without the escape code.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds the characters that take two septets, an escape code
+// followed by the character.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Count describes the size of a text once sent as SMS.
+type Count struct {
+	// Characters is the number of characters of the text.
+	Characters int
+	// Encoding is the encoding the text is sent in.
+	Encoding Encoding
+	// Segments is the number of SMS the text is split into.
+	Segments int
+}
+
+// Measure returns the size of text once sent as SMS.
+func Measure(text string) Count {
+	count := Count{Characters: utf8.RuneCountInString(text)}
+
+	if IsGSM7(text) {
+		count.Encoding = GSM7
+		count.Segments = segments(text, gsm7SingleSegment, gsm7MultiSegment, septets)
+	} else {
+		count.Encoding = UCS2
+		count.Segments = segments(text, ucs2SingleSegment, ucs2MultiSegment, utf16Units)
+	}
+	return count
+}
+
+// IsGSM7 reports whether text can be sent using the GSM 7-bit alphabet.
+func IsGSM7(text string) bool {
+	for _, r := range text {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// segments splits text into segments of at most single units if it fits in
+// one, or multi units otherwise. Characters are never split across segments.
+func segments(text string, single, multi int, size func(rune) int) int {
+	total := 0
+	for _, r := range text {
+		total += size(r)
+	}
+	if total == 0 {
+		return 0
+	}
+	if total <= single {
+		return 1
+	}
+
+	count, used := 1, 0
+	for _, r := range text {
+		n := size(r)
+		if used+n > multi {
+			count++
+			used = 0
+		}
+		used += n
+	}
+	return count
+}
+
+func septets(r rune) int {
+	if strings.ContainsRune(gsm7Extension, r) {
+		return 2
+	}
+	return 1
+}
+
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// gsm7Replacements spells common characters outside of the GSM alphabet with
+// characters of it.
+var gsm7Replacements = map[rune]string{
+	'‘': "'", '’': "'", '‚': "'", '′': "'",
+	'“': "\"", '”': "\"", '„': "\"", '″': "\"",
+	'–': "-", '—': "-", '−': "-", '‐': "-",
+	'…': "...", '•': "*", '·': ".", '×': "x",
+	'\t': " ", '\u00a0': " ",
+	'á': "a", 'â': "a", 'ã': "a", 'ā': "a",
+	'ê': "e", 'ë': "e", 'ē': "e",
+	'í': "i", 'î': "i", 'ï': "i",
+	'ó': "o", 'ô': "o", 'õ': "o", 'ō': "o",
+	'ú': "u", 'û': "u", 'ū': "u",
+	'ç': "c", 'ý': "y", 'ÿ': "y",
+	'Á': "A", 'À': "A", 'Â': "A", 'Ã': "A",
+	'È': "E", 'Ê': "E", 'Ë': "E",
+	'Í': "I", 'Ì': "I", 'Î': "I", 'Ï': "I",
+	'Ó': "O", 'Ò': "O", 'Ô': "O", 'Õ': "O",
+	'Ú': "U", 'Ù': "U", 'Û': "U",
+}
+
+// ToGSM7 returns text with the characters outside of the GSM 7-bit alphabet
+// replaced, so that it is not sent as UCS-2: typographic quotes and dashes
+// and accented letters by their plain equivalent, and other characters by ?.
+func ToGSM7(text string) string {
+	if IsGSM7(text) {
+		return text
+	}
+
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r) || strings.ContainsRune(gsm7Extension, r):
+			b.WriteRune(r)
+		case gsm7Replacements[r] != "":
+			b.WriteString(gsm7Replacements[r])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
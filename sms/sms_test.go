@@ -0,0 +1,72 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		text string
+		exp  Count
+	}{
+		{
+			name: "empty",
+			text: "",
+			exp:  Count{Encoding: GSM7},
+		},
+		{
+			name: "single gsm7",
+			text: strings.Repeat("a", 160),
+			exp:  Count{Characters: 160, Encoding: GSM7, Segments: 1},
+		},
+		{
+			name: "multi gsm7",
+			text: strings.Repeat("a", 161),
+			exp:  Count{Characters: 161, Encoding: GSM7, Segments: 2},
+		},
+		{
+			name: "extension characters take two septets",
+			text: strings.Repeat("€", 80) + "a",
+			exp:  Count{Characters: 81, Encoding: GSM7, Segments: 2},
+		},
+		{
+			name: "extension character is not split",
+			text: strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152),
+			exp:  Count{Characters: 305, Encoding: GSM7, Segments: 3},
+		},
+		{
+			name: "single ucs2",
+			text: strings.Repeat("ж", 70),
+			exp:  Count{Characters: 70, Encoding: UCS2, Segments: 1},
+		},
+		{
+			name: "multi ucs2",
+			text: strings.Repeat("ж", 135),
+			exp:  Count{Characters: 135, Encoding: UCS2, Segments: 3},
+		},
+		{
+			name: "surrogate pairs",
+			text: strings.Repeat("🔥", 35) + "a",
+			exp:  Count{Characters: 36, Encoding: UCS2, Segments: 2},
+		},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.exp, Measure(tc.text), tc.name)
+	}
+}
+
+func TestToGSM7(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Disk full: 99% on db-1 @ £5", ToGSM7("Disk full: 99% on db-1 @ £5"))
+	assert.Equal(t, `"Hot" - it's at 97?C...`, ToGSM7("“Hot” – it’s at 97°C…"))
+	assert.Equal(t, "Café à Sao Paulo", ToGSM7("Café à São Paulo"))
+	assert.Equal(t, "? fire", ToGSM7("🔥 fire"))
+	assert.True(t, IsGSM7(ToGSM7("“Hot” – it’s at 97°C…")))
+}